@@ -1,6 +1,7 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -13,12 +14,22 @@ type SwitchList struct {
 	IP     string
 }
 
-func Akips_get_switch_list() error {
+func (s *Store) Akips_get_switch_list() error {
+	return s.Akips_get_switch_list_context(context.Background())
+}
+
+// Akips_get_switch_list_context is Akips_get_switch_list with a context that cancels the collection and its database writes.
+func (s *Store) Akips_get_switch_list_context(ctx context.Context) error {
 	var missing_switch_list []SwitchList
 
-	switch_list := akips.Switch_list()
+	switch_list, err := run_context(ctx, func() ([]akips.SwitchList, error) {
+		return akips.Switch_list(), nil
+	})
+	if err != nil {
+		return err
+	}
 
-	switch_records := Device_all()
+	switch_records := s.Device_all_context(ctx)
 	// Iterate through each line from the URL list
 	for _, current_switch := range switch_list {
 		found := false // Flag to track if we find a match
@@ -61,20 +72,13 @@ func Akips_get_switch_list() error {
 
 		finalQuery := sqlStr + strings.Join(valueStrings, ",")
 
-		// Establish the database connection.
-		db, err := DB_connect()
-		if err != nil {
-			log.Print(err)
-		}
-		defer db.Close()
-
-		tx, err := db.Begin()
+		tx, err := s.DB.BeginTx(ctx, nil)
 		if err != nil {
 			log.Printf("Failed to begin transaction for %s: %v", "Akips switch list", err)
 		}
 
-		// Use tx.Exec() with the final query and the flat slice of all values
-		_, err = tx.Exec(finalQuery, valueArgs...)
+		// Use tx.ExecContext() with the final query and the flat slice of all values
+		_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 		if err != nil {
 			// Roll back the transaction if there's an error
 			tx.Rollback()
@@ -95,34 +99,44 @@ func Akips_get_switch_list() error {
 
 }
 
-func Akips_get_interface_usage(switch_id int64, switch_hostname string) error {
-	interface_usage := akips.Interface_usage(switch_hostname)
+func (s *Store) Akips_get_interface_usage(switch_id int64, switch_hostname string) error {
+	return s.Akips_get_interface_usage_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Akips_get_interface_usage_context is Akips_get_interface_usage with a context that cancels the collection and its database writes.
+func (s *Store) Akips_get_interface_usage_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Akips_get_interface_usage", func(ctx context.Context) (int64, error) {
+		return s.akips_get_interface_usage(ctx, switch_id, switch_hostname)
+	})
+}
+
+// akips_get_interface_usage collects the command output and returns the number of rows stored.
+func (s *Store) akips_get_interface_usage(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	interface_usage, err := run_context(ctx, func() ([]akips.InterfaceUsage, error) {
+		return akips.Interface_usage(switch_hostname), nil
+	})
+	if err != nil {
+		return 0, err
+	}
 
 	// Check the length of the slice, not the map.
 	if len(interface_usage) == 0 {
 		log.Printf("AKIPS Interface Usage ::Warning: Parsing completed for %s, but no interfaces were found.", switch_hostname)
-		return nil
+		return 0, nil
 	}
 
-	// Establish the database connection.
-	db, err := DB_connect()
-	if err != nil {
-		log.Print(err)
-	}
-	defer db.Close()
-
-	// Delete records from today
-	deleteQuery := fmt.Sprintf("DELETE FROM akips_interface_usage WHERE switch_id = %d", switch_id)
-	Execute_query(db, deleteQuery)
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM akips_interface_usage WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
 
 	sqlStr := "INSERT INTO `akips_interface_usage` (" +
-		"`switch_id`, `interface`, `status`, `last_change`, `days`, `hours`, `minutes`) VALUES "
+		"`switch_id`, `run_id`, `interface`, `status`, `last_change`, `days`, `hours`, `minutes`) VALUES "
 	// 2. Create slices for the placeholder strings and the actual values
 	var valueStrings []string
 	var valueArgs []any // Use []any (or []interface{})
 
 	// This is the placeholder group for a SINGLE row (25 placeholders)
-	placeholderRow := "(?, ?, ?, ?, ?, ?, ?)"
+	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?)"
 
 	for _, current_interface := range interface_usage {
 		// Add the placeholder group for this row
@@ -130,6 +144,7 @@ func Akips_get_interface_usage(switch_id int64, switch_hostname string) error {
 		// Add the values for this row, in the *exact* same order as the columns above
 		valueArgs = append(valueArgs,
 			switch_id, // The switch_id from the function argument
+			run_arg(ctx),
 			current_interface.Interface,
 			current_interface.Status,
 			current_interface.Last_change,
@@ -141,30 +156,66 @@ func Akips_get_interface_usage(switch_id int64, switch_hostname string) error {
 
 	finalQuery := sqlStr + strings.Join(valueStrings, ",")
 
-	tx, err := db.Begin()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
-	// Use tx.Exec() with the final query and the flat slice of all values
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	// Use tx.ExecContext() with the final query and the flat slice of all values
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		// Roll back the transaction if there's an error
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s: %v", switch_hostname, err)
 		log.Printf("Failed query: %s", finalQuery) // Log the query for debugging
-		return err
+		return 0, err
 	}
 
 	// If successful, commit the transaction
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
 	log.Printf("%d :: %s :: AKIPS Interface Usage :: %d records inserted.\n", switch_id, switch_hostname, len(interface_usage))
 
-	return nil
+	return int64(len(interface_usage)), nil
+}
+
+// Akips_get_switch_list runs s.Akips_get_switch_list against Default_store.
+func Akips_get_switch_list() error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Akips_get_switch_list()
+}
+
+// Akips_get_switch_list_context runs s.Akips_get_switch_list_context against Default_store.
+func Akips_get_switch_list_context(ctx context.Context) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Akips_get_switch_list_context(ctx)
+}
+
+// Akips_get_interface_usage runs s.Akips_get_interface_usage against Default_store.
+func Akips_get_interface_usage(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Akips_get_interface_usage(switch_id, switch_hostname)
+}
+
+// Akips_get_interface_usage_context runs s.Akips_get_interface_usage_context against Default_store.
+func Akips_get_interface_usage_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Akips_get_interface_usage_context(ctx, switch_id, switch_hostname)
 }
@@ -0,0 +1,347 @@
+package cisco_database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Change types stored in changes.change_type.
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// Change is one difference between two consecutive runs of a switch.
+// Field, Old and New are set for modified entities; added and removed
+// entities have no field.
+type Change struct {
+	ID            int64
+	SwitchID      int64
+	RunID         int64
+	PreviousRunID int64
+	Entity        string // the table, e.g. "interfaces_status"
+	Key           string // e.g. "Gi1/0/1", or "Gi1/0/1|neighbor-name" for neighbors
+	Interface     string // empty for vlans
+	Type          string
+	Field         string
+	Old           string
+	New           string
+	DetectedAt    time.Time
+}
+
+// change_entity describes how rows of a table are matched and compared between runs.
+type change_entity struct {
+	table  string
+	step   string   // the collector filling the table
+	keys   []string // columns identifying a row within a switch
+	fields []string // columns compared between runs
+}
+
+// change_entities are the tables compared by Detect_changes. The interfaces fields filled in
+// later by Update_interfaces (vlan, mac, ip, fqdn) are left out, interfaces_status carries the VLAN.
+var change_entities = []change_entity{
+	{table: "interfaces", step: "Show_interfaces", keys: []string{"interface"}, fields: []string{"description", "link_status", "protocol_status", "duplex", "speed", "media_type", "mtu", "bandwidth", "encapsulation"}},
+	{table: "interfaces_status", step: "Show_interfaces_status", keys: []string{"interface"}, fields: []string{"description", "status", "vlan_id", "duplex", "speed", "type"}},
+	{table: "vlans", step: "Show_vlan", keys: []string{"vlan_id"}, fields: []string{"vlan_name", "status", "interfaces"}},
+	{table: "cdp_neighbors", step: "Show_cdp_neighbors", keys: []string{"interface", "neighbor_name"}, fields: []string{"neighbor_interface", "capabilities", "platform"}},
+	{table: "lldp_neighbors", step: "Show_lldp_neighbors", keys: []string{"interface", "neighbor_name"}, fields: []string{"neighbor_interface", "capabilities"}},
+	{table: "inventory", step: "Show_inventory", keys: []string{"name"}, fields: []string{"description", "pid", "vid", "serial"}},
+	{table: "power_interfaces", step: "Show_power_inline", keys: []string{"interface"}, fields: []string{"admin", "oper", "power", "device", "class", "max"}},
+}
+
+// Detect_changes compares, table by table, the latest run of the switch to the run before it
+// and stores the differences in the changes table. Runs already compared are skipped,
+// so it is safe to call repeatedly. It returns the changes it stored.
+func (s *Store) Detect_changes(ctx context.Context, switch_id int64) ([]Change, error) {
+	var stored []Change
+	for _, entity := range change_entities {
+		changes, err := s.detect_entity_changes(ctx, switch_id, entity)
+		if err != nil {
+			return stored, err
+		}
+		stored = append(stored, changes...)
+	}
+	return stored, nil
+}
+
+// detect_changes is the Detect_changes collector run after the other steps of a switch.
+func (s *Store) detect_changes(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	changes, err := s.Detect_changes(ctx, switch_id)
+	if err != nil {
+		return 0, err
+	}
+	if len(changes) > 0 {
+		log.Printf("%d :: %s :: Detect changes :: %d changes recorded.\n", switch_id, switch_hostname, len(changes))
+	}
+	return int64(len(changes)), nil
+}
+
+func (s *Store) detect_entity_changes(ctx context.Context, switch_id int64, entity change_entity) ([]Change, error) {
+	// The two latest runs in which the step succeeded, with or without rows, so that a run storing
+	// nothing compares as an empty snapshot. Runs that stored rows without a recorded step (collected
+	// before collection_steps, or by a Show_*_context call) count as well; runs where the step failed do not.
+	runs, err := Return_query_context(ctx, s.DB, "SELECT `run_id` FROM ("+
+		"SELECT `run_id` FROM `collection_steps` WHERE `switch_id` = ? AND `step` = ? AND `status` = ? "+
+		"UNION SELECT DISTINCT `run_id` FROM `"+entity.table+"` WHERE `switch_id` = ? AND `run_id` IS NOT NULL) candidates "+
+		"WHERE `run_id` NOT IN (SELECT `run_id` FROM `collection_steps` WHERE `switch_id` = ? AND `step` = ? AND `status` <> ?) "+
+		"ORDER BY `run_id` DESC LIMIT 2",
+		switch_id, entity.step, string(StepOK), switch_id, switch_id, entity.step, string(StepOK))
+	if err != nil {
+		return nil, fmt.Errorf("error reading runs of %s: %w", entity.table, err)
+	}
+	if len(runs) < 2 {
+		return nil, nil
+	}
+	run_id, previous_run_id := Row_int64(runs[0], "run_id"), Row_int64(runs[1], "run_id")
+
+	done, err := Return_query_context(ctx, s.DB, "SELECT 1 FROM `changes_checked` WHERE `switch_id` = ? AND `entity` = ? AND `run_id` = ?", switch_id, entity.table, run_id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading compared runs: %w", err)
+	}
+	if len(done) > 0 {
+		return nil, nil
+	}
+
+	current, err := s.entity_snapshot(ctx, switch_id, run_id, entity)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.entity_snapshot(ctx, switch_id, previous_run_id, entity)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Diff_snapshots(entity.table, previous, current, entity.fields)
+	now := time.Now().UTC()
+	for i := range changes {
+		changes[i].SwitchID = switch_id
+		changes[i].RunID = run_id
+		changes[i].PreviousRunID = previous_run_id
+		changes[i].DetectedAt = now
+		if entity.keys[0] == "interface" {
+			changes[i].Interface, _, _ = strings.Cut(changes[i].Key, "|")
+		}
+	}
+
+	stored, err := s.insert_changes(ctx, switch_id, entity.table, run_id, previous_run_id, now, changes)
+	if err != nil || !stored {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// entity_snapshot reads the rows of one run keyed by the entity key columns joined with "|".
+func (s *Store) entity_snapshot(ctx context.Context, switch_id int64, run_id int64, entity change_entity) (map[string]map[string]string, error) {
+	columns := append(append([]string{}, entity.keys...), entity.fields...)
+	query := "SELECT `" + strings.Join(columns, "`, `") + "` FROM `" + entity.table + "` WHERE `switch_id` = ? AND `run_id` = ?"
+	rows, err := Return_query_context(ctx, s.DB, query, switch_id, run_id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s run %d: %w", entity.table, run_id, err)
+	}
+
+	snapshot := make(map[string]map[string]string, len(rows))
+	for _, row := range rows {
+		var key []string
+		for _, column := range entity.keys {
+			key = append(key, Row_string(row, column))
+		}
+		values := make(map[string]string, len(entity.fields))
+		for _, field := range entity.fields {
+			values[field] = Row_string(row, field)
+		}
+		snapshot[strings.Join(key, "|")] = values
+	}
+	return snapshot, nil
+}
+
+// Diff_snapshots returns the changes between two snapshots of an entity, keyed by entity key
+// and then by field, in key order. Only Entity, Key, Type, Field, Old and New are set.
+func Diff_snapshots(entity string, previous, current map[string]map[string]string, fields []string) []Change {
+	keys := make(map[string]bool)
+	for key := range previous {
+		keys[key] = true
+	}
+	for key := range current {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, key := range sorted {
+		before, existed := previous[key]
+		after, exists := current[key]
+		switch {
+		case !existed:
+			changes = append(changes, Change{Entity: entity, Key: key, Type: ChangeAdded})
+		case !exists:
+			changes = append(changes, Change{Entity: entity, Key: key, Type: ChangeRemoved})
+		default:
+			for _, field := range fields {
+				if before[field] != after[field] {
+					changes = append(changes, Change{Entity: entity, Key: key, Type: ChangeModified, Field: field, Old: before[field], New: after[field]})
+				}
+			}
+		}
+	}
+	return changes
+}
+
+// insert_changes stores the changes and marks the runs as compared in one transaction.
+// It stores nothing and returns false when another process compared the runs first.
+func (s *Store) insert_changes(ctx context.Context, switch_id int64, entity string, run_id, previous_run_id int64, checked_at time.Time, changes []Change) (bool, error) {
+	sqlStr := "INSERT INTO `changes` (`switch_id`, `run_id`, `previous_run_id`, `entity`, `entity_key`, `interface`, `change_type`, `field`, `old_value`, `new_value`, `detected_at`) VALUES "
+	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	const batchSize = 1000
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO `changes_checked` (`switch_id`, `entity`, `run_id`, `previous_run_id`, `checked_at`) VALUES (?, ?, ?, ?, ?)", switch_id, entity, run_id, previous_run_id, checked_at)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error recording compared runs: %w", err)
+	}
+
+	for i := 0; i < len(changes); i += batchSize {
+		end := min(i+batchSize, len(changes))
+
+		var valueStrings []string
+		var valueArgs []any
+		for _, change := range changes[i:end] {
+			valueStrings = append(valueStrings, placeholderRow)
+			valueArgs = append(valueArgs,
+				change.SwitchID,
+				change.RunID,
+				change.PreviousRunID,
+				change.Entity,
+				change.Key,
+				null_string(change.Interface),
+				change.Type,
+				null_string(change.Field),
+				null_string(change.Old),
+				null_string(change.New),
+				change.DetectedAt,
+			)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr+strings.Join(valueStrings, ","), valueArgs...); err != nil {
+			return false, fmt.Errorf("error inserting changes: %w", err)
+		}
+	}
+	return true, tx.Commit()
+}
+
+// null_string stores an empty string as NULL.
+func null_string(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// ChangeFilter selects the changes returned by Changes. Zero fields do not filter.
+type ChangeFilter struct {
+	SwitchID  int64
+	Interface string
+	Entity    string
+	Since     time.Time
+	Until     time.Time
+	// Limit caps the number of changes returned, newest first. Default 1000.
+	Limit int
+}
+
+// Changes returns the recorded changes matching filter, newest first.
+func (s *Store) Changes(ctx context.Context, filter ChangeFilter) ([]Change, error) {
+	var where []string
+	var args []any
+	if filter.SwitchID != 0 {
+		where = append(where, "`switch_id` = ?")
+		args = append(args, filter.SwitchID)
+	}
+	if filter.Interface != "" {
+		where = append(where, "`interface` = ?")
+		args = append(args, filter.Interface)
+	}
+	if filter.Entity != "" {
+		where = append(where, "`entity` = ?")
+		args = append(args, filter.Entity)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "`detected_at` >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "`detected_at` < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 1000
+	}
+
+	query := "SELECT * FROM `changes`"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY `detected_at` DESC, `id` DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := Return_query_context(ctx, s.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error reading changes: %w", err)
+	}
+
+	changes := make([]Change, 0, len(rows))
+	for _, row := range rows {
+		detected, _ := row["detected_at"].(time.Time)
+		changes = append(changes, Change{
+			ID:            Row_int64(row, "id"),
+			SwitchID:      Row_int64(row, "switch_id"),
+			RunID:         Row_int64(row, "run_id"),
+			PreviousRunID: Row_int64(row, "previous_run_id"),
+			Entity:        Row_string(row, "entity"),
+			Key:           Row_string(row, "entity_key"),
+			Interface:     Row_string(row, "interface"),
+			Type:          Row_string(row, "change_type"),
+			Field:         Row_string(row, "field"),
+			Old:           Row_string(row, "old_value"),
+			New:           Row_string(row, "new_value"),
+			DetectedAt:    detected,
+		})
+	}
+	return changes, nil
+}
+
+// Detect_changes runs s.Detect_changes against Default_store.
+func Detect_changes(ctx context.Context, switch_id int64) ([]Change, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Detect_changes(ctx, switch_id)
+}
+
+// Changes runs s.Changes against Default_store.
+func Changes(ctx context.Context, filter ChangeFilter) ([]Change, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Changes(ctx, filter)
+}
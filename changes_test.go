@@ -0,0 +1,56 @@
+package cisco_database
+
+import (
+	"testing"
+)
+
+func TestDiffSnapshotsEmptyCurrent(t *testing.T) {
+	previous := map[string]map[string]string{
+		"Gi1/0/1|phone1": {"neighbor_interface": "Port 1", "capabilities": "H P", "platform": "IP Phone"},
+		"Gi1/0/2|ap1":    {"neighbor_interface": "Gi0", "capabilities": "T", "platform": "AIR-AP"},
+	}
+	changes := Diff_snapshots("cdp_neighbors", previous, map[string]map[string]string{}, []string{"neighbor_interface", "capabilities", "platform"})
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2: %+v", len(changes), changes)
+	}
+	for i, key := range []string{"Gi1/0/1|phone1", "Gi1/0/2|ap1"} {
+		if changes[i].Key != key || changes[i].Type != ChangeRemoved {
+			t.Errorf("change %d = %s %s, want %s %s", i, changes[i].Key, changes[i].Type, key, ChangeRemoved)
+		}
+	}
+}
+
+func TestDiffSnapshotsModified(t *testing.T) {
+	previous := map[string]map[string]string{"Gi1/0/1": {"status": "connected", "vlan_id": "10"}}
+	current := map[string]map[string]string{
+		"Gi1/0/1": {"status": "notconnect", "vlan_id": "10"},
+		"Gi1/0/3": {"status": "connected", "vlan_id": "20"},
+	}
+	changes := Diff_snapshots("interfaces_status", previous, current, []string{"status", "vlan_id"})
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2: %+v", len(changes), changes)
+	}
+	if c := changes[0]; c.Type != ChangeModified || c.Field != "status" || c.Old != "connected" || c.New != "notconnect" {
+		t.Errorf("changes[0] = %+v", c)
+	}
+	if c := changes[1]; c.Type != ChangeAdded || c.Key != "Gi1/0/3" {
+		t.Errorf("changes[1] = %+v", c)
+	}
+}
+
+func TestChangeEntitiesNameTheirCollector(t *testing.T) {
+	registry := Default_registry(&Store{})
+	plan, err := registry.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, c := range plan {
+		names[c.Name()] = true
+	}
+	for _, entity := range change_entities {
+		if !names[entity.step] {
+			t.Errorf("change entity %s names unknown collector %q", entity.table, entity.step)
+		}
+	}
+}
@@ -1,112 +1,352 @@
 package cisco_database
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"time"
 )
 
-func Truncate_tables() {
+// Deprecated: Truncate_tables wipes the collected history. Use Prune with a RetentionPolicy.
+func (s *Store) Truncate_tables() {
+	s.Truncate_tables_context(context.Background())
+}
+
+// Deprecated: Truncate_tables_context wipes the collected history. Use Prune with a RetentionPolicy.
+func (s *Store) Truncate_tables_context(ctx context.Context) {
 	log.Printf("Trunkating tables...")
-	Truncate_table("interfaces_status")
-	Truncate_table("power_modules")
-	Truncate_table("power_interfaces")
-	Truncate_table("vlans")
-	Truncate_table("cdp_neighbors")
-	Truncate_table("lldp_neighbors")
-	Truncate_table("mac_address_table")
-	Truncate_table("show_running_config")
-	Truncate_table("akips_interface_usage")
-	Truncate_table("arp_table")
-	Truncate_table("vendors")
+	s.Truncate_table_context(ctx, "interfaces_status")
+	s.Truncate_table_context(ctx, "power_modules")
+	s.Truncate_table_context(ctx, "power_interfaces")
+	s.Truncate_table_context(ctx, "vlans")
+	s.Truncate_table_context(ctx, "cdp_neighbors")
+	s.Truncate_table_context(ctx, "lldp_neighbors")
+	s.Truncate_table_context(ctx, "mac_address_table")
+	s.Truncate_table_context(ctx, "show_running_config")
+	s.Truncate_table_context(ctx, "akips_interface_usage")
+	s.Truncate_table_context(ctx, "arp_table")
 }
 
-func Update_interfaces() {
+func (s *Store) Update_interfaces() {
+	s.Update_interfaces_context(context.Background())
+}
+
+func (s *Store) Update_interfaces_context(ctx context.Context) {
 	log.Printf("Updating interfaces mac_address")
-	Update_interfaces_mac_address()
+	s.Update_interfaces_mac_address_context(ctx)
 	log.Println("Waiting 3 seconds before next Update...")
-	time.Sleep(3 * time.Second)
+	if sleep_context(ctx, 3*time.Second) != nil {
+		return
+	}
 
 	log.Printf("Updating interfaces vlan_id")
-	Update_interfaces_vlan_id()
+	s.Update_interfaces_vlan_id_context(ctx)
 	log.Println("Waiting 3 seconds before next Update...")
-	time.Sleep(3 * time.Second)
+	if sleep_context(ctx, 3*time.Second) != nil {
+		return
+	}
 
 	log.Printf("Updating interfaces vlan_name")
-	Update_interfaces_vlan_name()
+	s.Update_interfaces_vlan_name_context(ctx)
+	log.Println("Waiting 3 seconds before next Update...")
+	if sleep_context(ctx, 3*time.Second) != nil {
+		return
+	}
+
+	log.Printf("Updating interfaces ip_address")
+	s.Update_interfaces_ip_address_context(ctx)
+
+	// A failed lookup only leaves fqdn_table stale, the names already stored are still joined below.
+	log.Printf("Updating fqdn_table")
+	if _, err := s.Update_fqdn_table(ctx); err != nil {
+		log.Printf("Error updating fqdn_table: %v", err)
+	}
+
+	log.Printf("Updating interfaces fqdn")
+	s.Update_interfaces_fqdn_context(ctx)
 }
 
-func Update_interfaces_by_switch_id(switch_id int64) {
+func (s *Store) Update_interfaces_by_switch_id(switch_id int64) {
+	s.Update_interfaces_by_switch_id_context(context.Background(), switch_id)
+}
+
+func (s *Store) Update_interfaces_by_switch_id_context(ctx context.Context, switch_id int64) {
 	log.Printf("Updating interfaces mac_address")
-	Update_interfaces_mac_address_by_switch_id(switch_id)
-	time.Sleep(1 * time.Second)
+	s.Update_interfaces_mac_address_by_switch_id_context(ctx, switch_id)
+	if sleep_context(ctx, 1*time.Second) != nil {
+		return
+	}
 
 	log.Printf("Updating interfaces vlan_id")
-	Update_interfaces_vlan_id_by_switch_id(switch_id)
-	time.Sleep(1 * time.Second)
+	s.Update_interfaces_vlan_id_by_switch_id_context(ctx, switch_id)
+	if sleep_context(ctx, 1*time.Second) != nil {
+		return
+	}
 
 	log.Printf("Updating interfaces vlan_name")
-	Update_interfaces_vlan_name_by_switch_id(switch_id)
+	s.Update_interfaces_vlan_name_by_switch_id_context(ctx, switch_id)
+	if sleep_context(ctx, 1*time.Second) != nil {
+		return
+	}
+
+	log.Printf("Updating interfaces ip_address")
+	s.Update_interfaces_ip_address_by_switch_id_context(ctx, switch_id)
 }
 
-func Process_switch(switch_id int64, fqdn string) {
-	err := Show_running_config(switch_id, fqdn)
+func (s *Store) Process_switch(switch_id int64, fqdn string) {
+	s.Process_switch_context(context.Background(), switch_id, fqdn)
+}
+
+// Process_switch_context is Process_switch with a context for per-switch deadlines and cancellation.
+// It returns the error of the first collector that failed.
+func (s *Store) Process_switch_context(ctx context.Context, switch_id int64, fqdn string) error {
+	return s.process_switch(ctx, switch_id, fqdn)
+}
+
+// process_switch runs every collector for one switch and returns the first error.
+func (s *Store) process_switch(ctx context.Context, switch_id int64, fqdn string) error {
+	return s.Process_switch_result(ctx, switch_id, fqdn, ProcessOptions{}).Err()
+}
+
+// Process_switch_result runs the collectors for one switch and reports every step.
+// By default it stops at the first failing step and marks the rest as skipped;
+// with opts.ContinueOnError every step runs independently.
+func (s *Store) Process_switch_result(ctx context.Context, switch_id int64, fqdn string, opts ProcessOptions) SwitchResult {
+	sw := Switch{ID: switch_id, Fqdn: fqdn}
+	// The switches row provides the role used to disable collectors.
+	rows, err := Return_query_context(ctx, s.DB, "SELECT * from switches WHERE id = ?", switch_id)
 	if err != nil {
-		log.Printf("ERROR [Show_running_config] %s: %v", fqdn, err)
-		return
+		log.Printf("Error reading data: %v", err)
+	} else if len(rows) > 0 {
+		sw = Switch_from_row(rows[0])
+		sw.Fqdn = fqdn
+	}
+
+	return s.Run_collectors(ctx, sw, opts)
+}
+
+// Run_collectors runs s.Collectors for one switch in dependency order.
+// A collector whose dependency did not succeed is skipped.
+// The rows are stored under the run carried by ctx (With_run), or under a new run for this switch.
+func (s *Store) Run_collectors(ctx context.Context, sw Switch, opts ProcessOptions) SwitchResult {
+	result := SwitchResult{SwitchID: sw.ID, Fqdn: sw.Fqdn, Started: time.Now()}
+
+	// Every row stored for the switch belongs to the run of ctx, or to a run of its own.
+	ctx, finish, err := s.ensure_run(ctx, "process_switch")
+	if err != nil {
+		log.Printf("ERROR [Collectors] %s: %v", sw.Fqdn, err)
+		result.Steps = append(result.Steps, StepResult{Name: "Collectors", Status: StepFailed, Err: err})
+		result.Duration = time.Since(result.Started)
+		return result
+	}
+	result.RunID = Run_from_context(ctx)
+	defer func() { finish(result.Status()) }()
+
+	registry := s.Collectors
+	if registry == nil {
+		registry = Default_registry(s)
 	}
 
-	err = Show_version(switch_id, fqdn)
+	plan, err := registry.Plan()
 	if err != nil {
-		log.Printf("ERROR [Show_version] %s: %v", fqdn, err)
-		return
+		log.Printf("ERROR [Collectors] %s: %v", sw.Fqdn, err)
+		result.Steps = append(result.Steps, StepResult{Name: "Collectors", Status: StepFailed, Err: err})
+		result.Duration = time.Since(result.Started)
+		return result
+	}
+
+	status := make(map[string]StepStatus)
+	stopped := false
+
+	// The reachability of the switch is checked before the steps; when it fails they would only time out.
+	// With SingleSession the login of the session is the probe, and the steps run their commands over it.
+	// Otherwise only the SSH port is probed, and the first device command a collector sends completes
+	// the probe, so a sweep does not authenticate twice per switch.
+	var probe Reachability
+	var login *device_login
+	probed, pending := false, false
+	switch {
+	case s.SingleSession && Session_from_context(ctx) == nil:
+		start := time.Now()
+		session, err := Open_session(ctx, sw.Fqdn)
+		probe = Reachability{State: Reachability_state(err), Err: err, Latency: time.Since(start)}
+		probed = true
+		if err == nil {
+			defer session.Close()
+			ctx = With_session(ctx, session)
+		}
+	case !opts.SkipReachability:
+		probe = s.Probe_port(ctx, sw.Fqdn, ssh_port)
+		probed = true
+		pending = probe.Err == nil
+		if pending {
+			ctx, login = with_device_login(ctx, sw.Fqdn)
+		}
+	}
+
+	if probed {
+		if !opts.SkipReachability && !pending {
+			if err := s.record_reachability(ctx, sw.ID, probe); err != nil {
+				log.Printf("ERROR [Reachability] %s: %v", sw.Fqdn, err)
+			}
+		}
+		step := StepResult{Name: "Reachability", Status: StepOK, Duration: probe.Latency}
+		if probe.Err != nil {
+			log.Printf("ERROR [Reachability] %s: %s: %v", sw.Fqdn, probe.State, probe.Err)
+			step.Status = StepFailed
+			step.Err = fmt.Errorf("%s: %w", probe.State, probe.Err)
+			step.Class = Classify_error(probe.Err)
+			stopped = !opts.ContinueOnError
+		}
+		result.Steps = append(result.Steps, step)
+	}
+
+	for _, c := range plan {
+		step := StepResult{Name: c.Name()}
+
+		switch {
+		case !registry.Enabled(c.Name(), sw):
+			step.Status = StepDisabled
+		case stopped || ctx.Err() != nil:
+			step.Status = StepSkipped
+		default:
+			for _, dependency := range c.Dependencies() {
+				if status[dependency] != StepOK {
+					step.Status = StepSkipped
+					step.Err = fmt.Errorf("dependency %s is %s", dependency, status[dependency])
+					break
+				}
+			}
+		}
+
+		if step.Status == "" {
+			stepStart := time.Now()
+			if rc, ok := c.(RowCollector); ok {
+				step.Rows, err = rc.Run_rows(ctx, sw)
+			} else {
+				err = c.Run(ctx, sw)
+			}
+			step.Duration = time.Since(stepStart)
+			step.Status = StepOK
+			if err != nil {
+				log.Printf("ERROR [%s] %s: %v", c.Name(), sw.Fqdn, err)
+				step.Status = StepFailed
+				step.Err = err
+				step.Class = Classify_error(err)
+				stopped = !opts.ContinueOnError
+			}
+		}
+
+		if pending {
+			if loginErr, sent := login.outcome(); sent {
+				pending = false
+				if err := s.record_reachability(ctx, sw.ID, login_reachability(probe, loginErr)); err != nil {
+					log.Printf("ERROR [Reachability] %s: %v", sw.Fqdn, err)
+				}
+			}
+		}
+
+		status[c.Name()] = step.Status
+		result.Steps = append(result.Steps, step)
+		// Detect_changes reads the outcome of the steps run before it.
+		if step.Status == StepOK || step.Status == StepFailed {
+			if err := s.record_step(ctx, result.RunID, sw.ID, step); err != nil {
+				log.Printf("ERROR [%s] %s: %v", c.Name(), sw.Fqdn, err)
+			}
+		}
+	}
+
+	// No collector sent a device command to confirm the login, the authenticated probe does.
+	if pending && ctx.Err() == nil {
+		if err := s.record_reachability(ctx, sw.ID, s.Probe_switch(ctx, sw.Fqdn)); err != nil {
+			log.Printf("ERROR [Reachability] %s: %v", sw.Fqdn, err)
+		}
 	}
-	err = Show_interfaces(switch_id, fqdn)
+
+	result.Duration = time.Since(result.Started)
+	return result
+}
+
+// --- PACKAGE-LEVEL WRAPPERS ---
+// These keep the original API and run against Default_store.
+
+// Deprecated: Truncate_tables wipes the collected history. Use Prune with a RetentionPolicy.
+func Truncate_tables() {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("ERROR [Show_interfaces] %s: %v", fqdn, err)
+		log.Print(err)
 		return
 	}
+	s.Truncate_tables()
+}
 
-	err = Show_interfaces_status(switch_id, fqdn)
+// Deprecated: Truncate_tables_context wipes the collected history. Use Prune with a RetentionPolicy.
+func Truncate_tables_context(ctx context.Context) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("ERROR [Show_interfaces_status] %s: %v", fqdn, err)
+		log.Print(err)
 		return
 	}
+	s.Truncate_tables_context(ctx)
+}
 
-	err = Show_cdp_neighbors(switch_id, fqdn)
+func Update_interfaces() {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("ERROR [Show_cdp_neighbors] %s: %v", fqdn, err)
+		log.Print(err)
 		return
 	}
+	s.Update_interfaces()
+}
 
-	err = Show_lldp_neighbors(switch_id, fqdn)
+func Update_interfaces_context(ctx context.Context) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("ERROR [Show_lldp_neighbors] %s: %v", fqdn, err)
+		log.Print(err)
 		return
 	}
+	s.Update_interfaces_context(ctx)
+}
 
-	err = Show_vlan(switch_id, fqdn)
+func Update_interfaces_by_switch_id(switch_id int64) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("ERROR [Show_vlan] %s: %v", fqdn, err)
+		log.Print(err)
 		return
 	}
+	s.Update_interfaces_by_switch_id(switch_id)
+}
 
-	err = Show_power_inline(switch_id, fqdn)
+func Update_interfaces_by_switch_id_context(ctx context.Context, switch_id int64) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("ERROR [Show_power_inline] %s: %v", fqdn, err)
+		log.Print(err)
 		return
 	}
+	s.Update_interfaces_by_switch_id_context(ctx, switch_id)
+}
 
-	err = Show_mac_address_table(switch_id, fqdn)
+func Process_switch(switch_id int64, fqdn string) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("ERROR [Show_mac_address_table] %s: %v", fqdn, err)
+		log.Print(err)
 		return
 	}
+	s.Process_switch(switch_id, fqdn)
+}
 
-	// Akips
-	err = Akips_get_interface_usage(switch_id, fqdn)
+func Process_switch_context(ctx context.Context, switch_id int64, fqdn string) error {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("ERROR AKIPS [Interface_usage] %s: %v", fqdn, err)
-		return
+		return err
 	}
+	return s.Process_switch_context(ctx, switch_id, fqdn)
+}
 
+func Process_switch_result(ctx context.Context, switch_id int64, fqdn string, opts ProcessOptions) SwitchResult {
+	s, err := Default_store()
+	if err != nil {
+		return SwitchResult{SwitchID: switch_id, Fqdn: fqdn, Started: time.Now(), Steps: []StepResult{{Name: "Default_store", Status: StepFailed, Err: err}}}
+	}
+	return s.Process_switch_result(ctx, switch_id, fqdn, opts)
 }
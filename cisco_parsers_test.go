@@ -0,0 +1,72 @@
+package cisco_database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The parsers used over a Session are copies of the unexported parsers of github.com/xtokio/cisco v1.0.7.
+// Each testdata/cisco/<name>.json was written by the upstream parser from <name>.txt, so these tests
+// fail as soon as a copy stops returning what cisco.Show_* returns for the same output.
+func TestCiscoParsersMatchUpstream(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) (any, error)
+	}{
+		{"show_version_ios", func(raw string) (any, error) { return parseVersionInfo(raw) }},
+		{"show_version_nxos", func(raw string) (any, error) { return parseVersionInfo(raw) }},
+		{"show_interfaces", func(raw string) (any, error) { return parseInterfaces(raw) }},
+		{"show_interfaces_status", func(raw string) (any, error) { return parseInterfaceStatus(raw) }},
+		{"show_vlan", func(raw string) (any, error) { return parseVlanInfo(raw) }},
+		{"show_power_inline", func(raw string) (any, error) {
+			modules, interfaces, err := parsePowerInline(raw)
+			return map[string]any{"modules": modules, "interfaces": interfaces}, err
+		}},
+		{"show_cdp_neighbors", func(raw string) (any, error) { return parseCdpNeighbors(raw) }},
+		{"show_lldp_neighbors", func(raw string) (any, error) { return parseLldpNeighbors(raw) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", "cisco", tt.name+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", "cisco", tt.name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := tt.parse(string(raw))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got, err := json.MarshalIndent(parsed, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got)+"\n" != string(want) {
+				t.Errorf("parsed %s.txt =\n%s\nwant, as cisco v1.0.7 parses it,\n%s", tt.name, got, want)
+			}
+		})
+	}
+}
+
+// The one deliberate difference: upstream slices CDP columns by the header offsets and panics on a
+// row shorter than the Port ID column, cdp_column returns the columns the row has.
+func TestCdpNeighborsShortRow(t *testing.T) {
+	raw := "Device ID        Local Intrfce     Holdtme    Capability  Platform  Port ID\n" +
+		"core1.example.com\n" +
+		"                 Gig 1/0/48        132        R S I       C9500\n"
+	neighbors, err := parseCdpNeighbors(raw)
+	if err != nil {
+		t.Fatalf("parseCdpNeighbors: %v", err)
+	}
+	if len(neighbors) != 1 {
+		t.Fatalf("parseCdpNeighbors = %+v, want one neighbor", neighbors)
+	}
+	if got := neighbors[0]; got.Neighbor != "core1.example.com" || got.Interface != "Gig 1/0/48" || got.Platform != "C9500" || got.NeighborInterface != "" {
+		t.Errorf("parseCdpNeighbors = %+v", got)
+	}
+}
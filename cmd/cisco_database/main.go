@@ -0,0 +1,344 @@
+// Command cisco_database manages the cisco_database MySQL schema.
+//
+//	cisco_database [-config file.yaml] migrate   apply the pending migrations
+//	cisco_database [-config file.yaml] status    show the schema version and pending migrations
+//	cisco_database [-config file.yaml] prune     delete the snapshots older than the retention policy
+//	cisco_database [-config file.yaml] config-versions <switch_id>       list the archived running-configs of a switch
+//	cisco_database [-config file.yaml] config-diff <from_id> [<to_id>]   unified diff between two config versions,
+//	                                                                     to the latest one of the switch by default
+//	cisco_database [-config file.yaml] compliance [<rules.yaml>]         check the archived configs and print the report,
+//	                                                                     with the compliance_rules of the config by default
+//	cisco_database [-config file.yaml] compliance-report                 print the stored compliance results
+//	cisco_database [-config file.yaml] reverse-dns                       resolve the IPs of arp_table into fqdn_table
+//	cisco_database [-config file.yaml] import-oui <path>...              load the IEEE MA-L, MA-M and MA-S CSV files into vendors
+//	cisco_database [-config file.yaml] vendor <mac>                      print the vendor of a MAC address
+//	cisco_database [-config file.yaml] import-ise [<export.csv>]         load the ISE endpoints into ise_ip_phones,
+//	                                                                     from the ISE API of the config by default
+//	cisco_database [-config file.yaml] find-serial <serial>              list where a serial number was last inventoried
+//	cisco_database [-config file.yaml] count-pids [<pattern>]            count the inventory entries by PID, e.g. "SFP%"
+//
+// The connection settings come from -config, MYSQL_DATABASE_CONFIG or the MYSQL_DATABASE_* variables.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/xtokio/cisco_database"
+)
+
+func main() {
+	configPath := flag.String("config", "", "YAML or TOML config file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-config file] migrate|status|prune|config-versions|config-diff|compliance|compliance-report|reverse-dns|import-oui|vendor|import-ise|find-serial|count-pids [args]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *configPath != "" {
+		cfg, err := cisco_database.Load_config(*configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cisco_database.Set_config(cfg)
+	}
+
+	store, err := cisco_database.Default_store()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	switch flag.Arg(0) {
+	case "migrate":
+		err = migrate(ctx, store)
+	case "status":
+		err = status(ctx, store)
+	case "prune":
+		err = prune(ctx, store)
+	case "config-versions":
+		err = config_versions(ctx, store, flag.Args()[1:])
+	case "config-diff":
+		err = config_diff(ctx, store, flag.Args()[1:])
+	case "compliance":
+		err = compliance(ctx, store, flag.Args()[1:])
+	case "compliance-report":
+		err = compliance_report(ctx, store)
+	case "reverse-dns":
+		err = reverse_dns(ctx, store)
+	case "import-oui":
+		err = import_oui(ctx, store, flag.Args()[1:])
+	case "vendor":
+		err = vendor(ctx, store, flag.Args()[1:])
+	case "import-ise":
+		err = import_ise(ctx, store, flag.Args()[1:])
+	case "find-serial":
+		err = find_serial(ctx, store, flag.Args()[1:])
+	case "count-pids":
+		err = count_pids(ctx, store, flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func migrate(ctx context.Context, store *cisco_database.Store) error {
+	applied, err := store.Migrate(ctx)
+	for _, m := range applied {
+		fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("database is up to date")
+	}
+	return nil
+}
+
+func status(ctx context.Context, store *cisco_database.Store) error {
+	version, err := cisco_database.Schema_version(ctx, store.DB)
+	if err != nil {
+		return err
+	}
+	pending, err := store.Pending_migrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("schema version %d\n", version)
+	for _, m := range pending {
+		fmt.Printf("pending %04d_%s\n", m.Version, m.Name)
+	}
+	if len(pending) == 0 {
+		fmt.Println("database is up to date")
+	}
+	return nil
+}
+
+func prune(ctx context.Context, store *cisco_database.Store) error {
+	cfg, err := cisco_database.Get_config()
+	if err != nil {
+		return err
+	}
+	policy := cfg.Retention
+	if policy.DefaultDays == 0 && len(policy.Tables) == 0 {
+		policy = cisco_database.Default_retention_policy()
+	}
+
+	result, err := store.Prune(ctx, policy)
+	if err != nil {
+		return err
+	}
+	for table, deleted := range result.Deleted {
+		fmt.Printf("%s: %d rows deleted\n", table, deleted)
+	}
+	fmt.Printf("collection_runs: %d rows deleted\n", result.Runs)
+	return nil
+}
+
+func config_versions(ctx context.Context, store *cisco_database.Store, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: config-versions <switch_id>")
+	}
+	switch_id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("error: invalid switch id %q", args[0])
+	}
+
+	versions, err := store.Config_versions(ctx, switch_id)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		fmt.Printf("%d\t%s\t%s\t%s\t%d lines\truns %d-%d\n", v.ID, v.Sha256[:12], v.FirstSeenAt.Format("2006-01-02 15:04:05"), v.LastSeenAt.Format("2006-01-02 15:04:05"), v.Lines, v.FirstRunID, v.LastRunID)
+	}
+	if len(versions) == 0 {
+		fmt.Printf("no config archived for switch %d\n", switch_id)
+	}
+	return nil
+}
+
+func config_diff(ctx context.Context, store *cisco_database.Store, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: config-diff <from_id> [<to_id>]")
+	}
+	var ids []int64
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("error: invalid config version id %q", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	from, err := store.Config_version(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	var to cisco_database.ConfigVersion
+	if len(ids) == 2 {
+		to, err = store.Config_version(ctx, ids[1])
+	} else {
+		to, err = store.Latest_config_version(ctx, from.SwitchID)
+	}
+	if err != nil {
+		return err
+	}
+	if from.SwitchID != to.SwitchID {
+		log.Printf("warning: comparing configs of switches %d and %d", from.SwitchID, to.SwitchID)
+	}
+
+	diff, err := cisco_database.Unified_config_diff(from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+	return nil
+}
+
+func compliance(ctx context.Context, store *cisco_database.Store, args []string) error {
+	rules := store.Compliance
+	switch {
+	case len(args) == 1:
+		var err error
+		if rules, err = cisco_database.Load_compliance_rules(args[0]); err != nil {
+			return err
+		}
+	case len(args) > 1:
+		return fmt.Errorf("usage: compliance [<rules.yaml>]")
+	case rules == nil:
+		return fmt.Errorf("error: no compliance rules, pass a rule file or set compliance_rules in the config")
+	}
+
+	if _, err := store.Check_compliance_all(ctx, rules); err != nil {
+		log.Print(err)
+	}
+	return compliance_report(ctx, store)
+}
+
+func compliance_report(ctx context.Context, store *cisco_database.Store) error {
+	report, err := store.Compliance_report(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println("rule\tseverity\tpassed\tfailed\tfailing switches")
+	for _, rule := range report.Rules {
+		fmt.Printf("%s\t%s\t%d\t%d\t%d\n", rule.RuleID, rule.Severity, rule.Passed, rule.Failed, rule.FailingSwitches)
+	}
+	fmt.Println()
+	fmt.Println("switch\tpassed\tfailed")
+	for _, sw := range report.Switches {
+		fmt.Printf("%s\t%d\t%d\n", sw.Fqdn, sw.Passed, sw.Failed)
+	}
+	return nil
+}
+
+func reverse_dns(ctx context.Context, store *cisco_database.Store) error {
+	result, err := store.Update_fqdn_table(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d addresses: %d resolved, %d without PTR, %d failed, %d rows updated\n", result.IPs, result.Resolved, result.NotFound, result.Failed, result.Rows)
+	return nil
+}
+
+func import_oui(ctx context.Context, store *cisco_database.Store, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: import-oui <path>...")
+	}
+	result, err := store.Import_oui(ctx, args...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d MA-L, %d MA-M, %d MA-S prefixes loaded, %d duplicates skipped\n", result.Registries["MA-L"], result.Registries["MA-M"], result.Registries["MA-S"], result.Duplicates)
+	return nil
+}
+
+func vendor(ctx context.Context, store *cisco_database.Store, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: vendor <mac>")
+	}
+	name, err := store.Vendor_by_mac(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	fmt.Println(name)
+	return nil
+}
+
+func import_ise(ctx context.Context, store *cisco_database.Store, args []string) error {
+	var result cisco_database.IseImportResult
+	var err error
+	switch len(args) {
+	case 0:
+		result, err = store.Import_ise(ctx)
+	case 1:
+		cfg, cfgErr := cisco_database.Get_config()
+		if cfgErr != nil {
+			return cfgErr
+		}
+		result, err = store.Import_ise_csv(ctx, args[0], cfg.ISE.Profiles...)
+	default:
+		return fmt.Errorf("usage: import-ise [<export.csv>]")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d endpoints stored, %d sessions failed\n", result.Endpoints, result.Failed)
+	return nil
+}
+
+func find_serial(ctx context.Context, store *cisco_database.Store, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: find-serial <serial>")
+	}
+	items, err := store.Find_serial(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		fmt.Printf("%s\t%s\t%s\t%s\trun %d\n", item.Fqdn, item.Name, item.PID, item.Serial, item.RunID)
+	}
+	if len(items) == 0 {
+		fmt.Printf("serial %s not found\n", args[0])
+	}
+	return nil
+}
+
+func count_pids(ctx context.Context, store *cisco_database.Store, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: count-pids [<pattern>]")
+	}
+	pattern := ""
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+	counts, err := store.Count_inventory_pids(ctx, pattern)
+	if err != nil {
+		return err
+	}
+	fmt.Println("pid\tcount\tswitches")
+	for _, c := range counts {
+		fmt.Printf("%s\t%d\t%d\n", c.PID, c.Count, c.Switches)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package cisco_database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CollectOptions controls a fleet-wide Collect_all run.
+type CollectOptions struct {
+	// Workers is the number of switches collected at the same time. Default 10.
+	Workers int
+	// SwitchTimeout bounds the time spent on a single switch. Default 10 minutes, negative disables it.
+	SwitchTimeout time.Duration
+	// SiteConcurrency limits how many switches of the same site are collected at once. 0 means no limit.
+	SiteConcurrency int
+	// Site returns the site of a switches row. Default Switch_site.
+	Site func(device map[string]interface{}) string
+	// Filter skips switches for which it returns false. Default collects every switch.
+	Filter func(device map[string]interface{}) bool
+	// ContinueOnError runs every step of a switch even when an earlier one failed.
+	ContinueOnError bool
+}
+
+// SwitchOutcome is the result of collecting a single switch in Collect_all.
+type SwitchOutcome struct {
+	SwitchID int64
+	Fqdn     string
+	Role     string
+	Site     string
+	Duration time.Duration
+	Err      error
+	// Result holds the per-step details. It is empty when the switch never started.
+	Result SwitchResult
+}
+
+// CollectSummary describes a finished Collect_all run.
+type CollectSummary struct {
+	// RunID is the collection run every switch of the sweep was stored under.
+	RunID     int64
+	Total     int
+	Succeeded int
+	Failed    int
+	Duration  time.Duration
+	Outcomes  []SwitchOutcome
+}
+
+// Failures returns the outcomes of the switches that failed.
+func (summary CollectSummary) Failures() []SwitchOutcome {
+	var failures []SwitchOutcome
+	for _, outcome := range summary.Outcomes {
+		if outcome.Err != nil {
+			failures = append(failures, outcome)
+		}
+	}
+	return failures
+}
+
+// Status returns the collection_runs status matching the summary.
+func (summary CollectSummary) Status() string {
+	switch {
+	case summary.Failed == 0:
+		return RunSucceeded
+	case summary.Succeeded > 0:
+		return RunPartial
+	default:
+		return RunFailed
+	}
+}
+
+// Switch_site returns the switch location when set, otherwise the domain part of its fqdn.
+func Switch_site(device map[string]interface{}) string {
+	if location := strings.TrimSpace(Row_string(device, "location")); location != "" {
+		return location
+	}
+	fqdn := Row_string(device, "fqdn")
+	if _, domain, found := strings.Cut(fqdn, "."); found {
+		return domain
+	}
+	return fqdn
+}
+
+// Collect_all runs Process_switch for every switch in the switches table using a bounded
+// pool of workers, and returns a summary of successes and failures.
+// The whole sweep is stored under a single collection run.
+func (s *Store) Collect_all(ctx context.Context, opts CollectOptions) (CollectSummary, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 10
+	}
+	if opts.SwitchTimeout == 0 {
+		opts.SwitchTimeout = 10 * time.Minute
+	}
+	if opts.Site == nil {
+		opts.Site = Switch_site
+	}
+
+	devices, err := Return_query_context(ctx, s.DB, "SELECT * from switches")
+	if err != nil {
+		return CollectSummary{}, fmt.Errorf("error reading switches: %w", err)
+	}
+
+	var jobs []SwitchOutcome
+	for _, device := range devices {
+		if opts.Filter != nil && !opts.Filter(device) {
+			continue
+		}
+		fqdn := Row_string(device, "fqdn")
+		if fqdn == "" {
+			continue
+		}
+		jobs = append(jobs, SwitchOutcome{SwitchID: Row_int64(device, "id"), Fqdn: fqdn, Role: Row_string(device, "role"), Site: opts.Site(device)})
+	}
+	// Interleave sites so a large site does not hold every worker waiting on its own limit.
+	jobs = interleave_sites(jobs)
+
+	run_id, err := s.Start_run(ctx, "collect_all")
+	if err != nil {
+		return CollectSummary{}, err
+	}
+	ctx = With_run(ctx, run_id)
+
+	start := time.Now()
+	log.Printf("Collect all :: run %d :: %d switches, %d workers", run_id, len(jobs), opts.Workers)
+
+	limiter := new_site_limiter(opts.SiteConcurrency)
+	jobsChan := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobsChan {
+				job := &jobs[i]
+				if err := limiter.acquire(ctx, job.Site); err != nil {
+					job.Err = err
+					continue
+				}
+				sw := Switch{ID: job.SwitchID, Fqdn: job.Fqdn, Role: job.Role, Site: job.Site}
+				job.Result = s.collect_switch(ctx, sw, opts)
+				job.Duration = job.Result.Duration
+				job.Err = job.Result.Err()
+				limiter.release(job.Site)
+			}
+		}()
+	}
+
+	for i := range jobs {
+		if ctx.Err() != nil {
+			jobs[i].Err = ctx.Err()
+			continue
+		}
+		jobsChan <- i
+	}
+	close(jobsChan)
+	wg.Wait()
+
+	summary := CollectSummary{RunID: run_id, Total: len(jobs), Duration: time.Since(start), Outcomes: jobs}
+	for _, job := range jobs {
+		if job.Err != nil {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+	}
+	if err := s.Finish_run(ctx, run_id, summary.Status()); err != nil {
+		log.Print(err)
+	}
+
+	log.Printf("Collect all :: %d switches, %d succeeded, %d failed in %s", summary.Total, summary.Succeeded, summary.Failed, summary.Duration.Round(time.Second))
+	for _, failure := range summary.Failures() {
+		log.Printf("Collect all :: FAILED %d :: %s :: %v", failure.SwitchID, failure.Fqdn, failure.Err)
+	}
+
+	return summary, ctx.Err()
+}
+
+// collect_switch runs the collectors of one switch with the per-switch timeout applied to ctx.
+func (s *Store) collect_switch(ctx context.Context, sw Switch, opts CollectOptions) SwitchResult {
+	if opts.SwitchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.SwitchTimeout)
+		defer cancel()
+	}
+	return s.Run_collectors(ctx, sw, ProcessOptions{ContinueOnError: opts.ContinueOnError})
+}
+
+// interleave_sites orders jobs round-robin across sites, keeping the original order within a site.
+func interleave_sites(jobs []SwitchOutcome) []SwitchOutcome {
+	bySite := make(map[string][]SwitchOutcome)
+	var sites []string
+	for _, job := range jobs {
+		if _, ok := bySite[job.Site]; !ok {
+			sites = append(sites, job.Site)
+		}
+		bySite[job.Site] = append(bySite[job.Site], job)
+	}
+	// Largest sites first so they start as early as possible.
+	sort.SliceStable(sites, func(i, j int) bool { return len(bySite[sites[i]]) > len(bySite[sites[j]]) })
+
+	ordered := make([]SwitchOutcome, 0, len(jobs))
+	for len(ordered) < len(jobs) {
+		for _, site := range sites {
+			if len(bySite[site]) > 0 {
+				ordered = append(ordered, bySite[site][0])
+				bySite[site] = bySite[site][1:]
+			}
+		}
+	}
+	return ordered
+}
+
+// site_limiter hands out at most limit slots per site.
+type site_limiter struct {
+	limit int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func new_site_limiter(limit int) *site_limiter {
+	return &site_limiter{limit: limit, slots: make(map[string]chan struct{})}
+}
+
+func (l *site_limiter) site_slots(site string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.slots[site]
+	if !ok {
+		slots = make(chan struct{}, l.limit)
+		l.slots[site] = slots
+	}
+	return slots
+}
+
+func (l *site_limiter) acquire(ctx context.Context, site string) error {
+	if l.limit <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case l.site_slots(site) <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *site_limiter) release(site string) {
+	if l.limit <= 0 {
+		return
+	}
+	<-l.site_slots(site)
+}
+
+// Collect_all runs s.Collect_all against Default_store.
+func Collect_all(ctx context.Context, opts CollectOptions) (CollectSummary, error) {
+	s, err := Default_store()
+	if err != nil {
+		return CollectSummary{}, err
+	}
+	return s.Collect_all(ctx, opts)
+}
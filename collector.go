@@ -0,0 +1,253 @@
+package cisco_database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Switch identifies the device a Collector runs against.
+type Switch struct {
+	ID   int64
+	Fqdn string
+	Role string
+	Site string
+}
+
+// Switch_from_row builds a Switch from a switches row returned by Return_query.
+func Switch_from_row(row map[string]interface{}) Switch {
+	return Switch{
+		ID:   Row_int64(row, "id"),
+		Fqdn: Row_string(row, "fqdn"),
+		Role: Row_string(row, "role"),
+		Site: Switch_site(row),
+	}
+}
+
+// Collector is one collection step run by Process_switch.
+// Dependencies names the collectors that must succeed before this one runs.
+type Collector interface {
+	Name() string
+	Dependencies() []string
+	Run(ctx context.Context, sw Switch) error
+}
+
+// RowCollector is implemented by collectors that report how many rows they stored.
+// Process_switch_result uses Run_rows instead of Run when it is available.
+type RowCollector interface {
+	Collector
+	Run_rows(ctx context.Context, sw Switch) (int64, error)
+}
+
+// func_collector adapts a function to the Collector interface.
+type func_collector struct {
+	name         string
+	dependencies []string
+	run          func(ctx context.Context, sw Switch) (int64, error)
+}
+
+func (c func_collector) Name() string           { return c.name }
+func (c func_collector) Dependencies() []string { return c.dependencies }
+
+func (c func_collector) Run(ctx context.Context, sw Switch) error {
+	_, err := c.run(ctx, sw)
+	return err
+}
+
+func (c func_collector) Run_rows(ctx context.Context, sw Switch) (int64, error) {
+	return c.run(ctx, sw)
+}
+
+// Collector_func returns a Collector that calls run, for site-specific commands.
+// run returns the number of rows it stored.
+func Collector_func(name string, dependencies []string, run func(ctx context.Context, sw Switch) (int64, error)) Collector {
+	return func_collector{name: name, dependencies: dependencies, run: run}
+}
+
+// store_collector wraps a Store method with the (switch_id, fqdn) signature used by the Show_* collectors.
+func store_collector(s *Store, name string, dependencies []string, run func(s *Store, ctx context.Context, switch_id int64, fqdn string) (int64, error)) Collector {
+	return Collector_func(name, dependencies, func(ctx context.Context, sw Switch) (int64, error) {
+		return run(s, ctx, sw.ID, sw.Fqdn)
+	})
+}
+
+// Default_registry returns a Registry with the built-in collectors in their historical order.
+func Default_registry(s *Store) *Registry {
+	r := New_registry()
+	for _, c := range []Collector{
+		store_collector(s, "Show_running_config", nil, (*Store).show_running_config),
+		store_collector(s, "Show_version", nil, (*Store).show_version),
+		store_collector(s, "Show_inventory", nil, (*Store).show_inventory),
+		store_collector(s, "Show_interfaces", nil, (*Store).show_interfaces),
+		store_collector(s, "Show_interfaces_status", nil, (*Store).show_interfaces_status),
+		store_collector(s, "Show_cdp_neighbors", nil, (*Store).show_cdp_neighbors),
+		store_collector(s, "Show_lldp_neighbors", nil, (*Store).show_lldp_neighbors),
+		store_collector(s, "Show_vlan", nil, (*Store).show_vlan),
+		store_collector(s, "Show_power_inline", nil, (*Store).show_power_inline),
+		// The MAC table command excludes the trunk ports stored by Show_interfaces_status.
+		store_collector(s, "Show_mac_address_table", []string{"Show_interfaces_status"}, (*Store).show_mac_address_table),
+		// Runs on the GatewayRoles switches only, after Show_running_config stored the VRFs of their interfaces
+		// and Show_version the software that tells NX-OS from IOS.
+		Collector_func("Show_ip_arp", []string{"Show_running_config", "Show_version"}, s.show_ip_arp_collector),
+		store_collector(s, "Akips_get_interface_usage", nil, (*Store).akips_get_interface_usage),
+		Collector_func("Check_compliance", []string{"Show_running_config"}, s.check_compliance),
+		// Registered last so it compares the rows stored by the steps above.
+		store_collector(s, "Detect_changes", nil, (*Store).detect_changes),
+	} {
+		r.Register(c)
+	}
+	return r
+}
+
+// Registry holds the collectors run for each switch and which ones are disabled.
+// It is safe for concurrent use.
+type Registry struct {
+	mu            sync.RWMutex
+	collectors    []Collector
+	disabled      map[string]bool
+	disabledRoles map[string]map[string]bool
+}
+
+// New_registry returns an empty Registry.
+func New_registry() *Registry {
+	return &Registry{
+		disabled:      make(map[string]bool),
+		disabledRoles: make(map[string]map[string]bool),
+	}
+}
+
+// Register adds a collector, or replaces the registered collector with the same name.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.collectors {
+		if existing.Name() == c.Name() {
+			r.collectors[i] = c
+			return
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// Unregister removes the named collectors.
+func (r *Registry) Unregister(names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	remove := make(map[string]bool)
+	for _, name := range names {
+		remove[name] = true
+	}
+	kept := r.collectors[:0]
+	for _, c := range r.collectors {
+		if !remove[c.Name()] {
+			kept = append(kept, c)
+		}
+	}
+	r.collectors = kept
+}
+
+// Disable stops the named collectors from running on any switch.
+func (r *Registry) Disable(names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range names {
+		r.disabled[name] = true
+	}
+}
+
+// Enable undoes Disable.
+func (r *Registry) Enable(names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range names {
+		delete(r.disabled, name)
+	}
+}
+
+// Disable_for_role stops the named collectors from running on switches with the given role.
+func (r *Registry) Disable_for_role(role string, names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	role = strings.ToLower(role)
+	if r.disabledRoles[role] == nil {
+		r.disabledRoles[role] = make(map[string]bool)
+	}
+	for _, name := range names {
+		r.disabledRoles[role][name] = true
+	}
+}
+
+// Enabled reports whether the named collector runs on the given switch.
+func (r *Registry) Enabled(name string, sw Switch) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.disabled[name] {
+		return false
+	}
+	return !r.disabledRoles[strings.ToLower(sw.Role)][name]
+}
+
+// Collectors returns the registered collectors in registration order.
+func (r *Registry) Collectors() []Collector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Collector(nil), r.collectors...)
+}
+
+// Plan orders the registered collectors so that every collector runs after its dependencies,
+// keeping registration order otherwise. It fails on unknown dependencies and cycles.
+func (r *Registry) Plan() ([]Collector, error) {
+	collectors := r.Collectors()
+
+	registered := make(map[string]bool)
+	for _, c := range collectors {
+		registered[c.Name()] = true
+	}
+	for _, c := range collectors {
+		for _, dependency := range c.Dependencies() {
+			if !registered[dependency] {
+				return nil, fmt.Errorf("collector %s depends on unknown collector %s", c.Name(), dependency)
+			}
+		}
+	}
+
+	planned := make(map[string]bool)
+	ordered := make([]Collector, 0, len(collectors))
+	for len(ordered) < len(collectors) {
+		progress := false
+		for _, c := range collectors {
+			if planned[c.Name()] || !dependencies_planned(c, planned) {
+				continue
+			}
+			planned[c.Name()] = true
+			ordered = append(ordered, c)
+			progress = true
+			// Restart from the top so registration order wins among ready collectors.
+			break
+		}
+		if !progress {
+			var cycle []string
+			for _, c := range collectors {
+				if !planned[c.Name()] {
+					cycle = append(cycle, c.Name())
+				}
+			}
+			return nil, fmt.Errorf("collector dependency cycle between %s", strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func dependencies_planned(c Collector, planned map[string]bool) bool {
+	for _, dependency := range c.Dependencies() {
+		if !planned[dependency] {
+			return false
+		}
+	}
+	return true
+}
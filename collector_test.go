@@ -0,0 +1,33 @@
+package cisco_database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDefaultRegistryOrder(t *testing.T) {
+	plan, err := Default_registry(&Store{}).Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	position := make(map[string]int)
+	for i, c := range plan {
+		position[c.Name()] = i
+	}
+	for _, c := range plan {
+		for _, dependency := range c.Dependencies() {
+			if _, ok := position[dependency]; !ok {
+				t.Errorf("%s depends on unknown collector %s", c.Name(), dependency)
+			} else if position[dependency] > position[c.Name()] {
+				t.Errorf("%s is planned before its dependency %s", c.Name(), dependency)
+			}
+		}
+	}
+
+	arp := plan[position["Show_ip_arp"]]
+	for _, dependency := range []string{"Show_running_config", "Show_version"} {
+		if !slices.Contains(arp.Dependencies(), dependency) {
+			t.Errorf("Show_ip_arp does not depend on %s", dependency)
+		}
+	}
+}
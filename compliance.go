@@ -0,0 +1,436 @@
+package cisco_database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ComplianceRule is one policy checked against the archived running-configs.
+// The checks apply to each section matched by Section, the whole config when it is empty.
+type ComplianceRule struct {
+	ID          string `yaml:"id" toml:"id"`
+	Description string `yaml:"description" toml:"description"`
+	Severity    string `yaml:"severity" toml:"severity"`
+	// Roles limits the rule to switches with these roles. Empty applies it to every switch.
+	Roles []string `yaml:"roles" toml:"roles"`
+	// Section is a Find path such as "interface *" or "line vty *".
+	Section string `yaml:"section" toml:"section"`
+	// When and Unless are Find paths inside the section: the rule only applies to the sections
+	// having every When path and none of the Unless paths, e.g. When "switchport mode access".
+	When   []string `yaml:"when" toml:"when"`
+	Unless []string `yaml:"unless" toml:"unless"`
+	// Require and Forbid are Find paths that must, or must not, be in the section.
+	Require []string `yaml:"require" toml:"require"`
+	Forbid  []string `yaml:"forbid" toml:"forbid"`
+	// Match and ForbidMatch are regular expressions that must, or must not, match the section text.
+	Match       []string `yaml:"match" toml:"match"`
+	ForbidMatch []string `yaml:"forbid_match" toml:"forbid_match"`
+
+	match       []*regexp.Regexp
+	forbidMatch []*regexp.Regexp
+}
+
+// ComplianceRules is a validated rule set, built by Load_compliance_rules or New_compliance_rules.
+type ComplianceRules struct {
+	Rules []ComplianceRule `yaml:"rules" toml:"rules"`
+}
+
+// ComplianceResult is the outcome of one rule on one section of a switch config.
+type ComplianceResult struct {
+	SwitchID        int64
+	ConfigVersionID int64
+	RuleID          string
+	Severity        string
+	// Section is the section line, empty for rules on the whole config.
+	Section string
+	// Interface is the normalized interface name of interface sections.
+	Interface string
+	Passed    bool
+	// Detail lists what failed.
+	Detail    string
+	CheckedAt time.Time
+}
+
+// Load_compliance_rules reads a YAML (.yaml/.yml) or TOML (.toml) rule file.
+func Load_compliance_rules(path string) (*ComplianceRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading compliance rules %s: %w", path, err)
+	}
+
+	var file ComplianceRules
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported compliance rules format %q (use .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing compliance rules %s: %w", path, err)
+	}
+	return New_compliance_rules(file.Rules)
+}
+
+// New_compliance_rules validates the rules and compiles their regular expressions.
+func New_compliance_rules(rules []ComplianceRule) (*ComplianceRules, error) {
+	seen := make(map[string]bool)
+	compiled := make([]ComplianceRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("error: compliance rule without id")
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("error: duplicate compliance rule id %s", rule.ID)
+		}
+		seen[rule.ID] = true
+		if len(rule.Require)+len(rule.Forbid)+len(rule.Match)+len(rule.ForbidMatch) == 0 {
+			return nil, fmt.Errorf("error: compliance rule %s has no require, forbid, match or forbid_match", rule.ID)
+		}
+
+		rule.match, rule.forbidMatch = nil, nil
+		for _, pattern := range rule.Match {
+			re, err := regexp.Compile("(?m)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("error compiling compliance rule %s: %w", rule.ID, err)
+			}
+			rule.match = append(rule.match, re)
+		}
+		for _, pattern := range rule.ForbidMatch {
+			re, err := regexp.Compile("(?m)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("error compiling compliance rule %s: %w", rule.ID, err)
+			}
+			rule.forbidMatch = append(rule.forbidMatch, re)
+		}
+		compiled = append(compiled, rule)
+	}
+	return &ComplianceRules{Rules: compiled}, nil
+}
+
+// Applies reports whether the rule applies to a switch with the given role.
+func (rule ComplianceRule) Applies(role string) bool {
+	return len(rule.Roles) == 0 || slices.ContainsFunc(rule.Roles, func(r string) bool { return strings.EqualFold(r, role) })
+}
+
+// Evaluate checks every rule applying to role against a config tree.
+// The results have no SwitchID, ConfigVersionID or CheckedAt.
+func (rules *ComplianceRules) Evaluate(role string, tree *ConfigNode) []ComplianceResult {
+	var results []ComplianceResult
+	for _, rule := range rules.Rules {
+		if !rule.Applies(role) {
+			continue
+		}
+		sections := []*ConfigNode{tree}
+		if rule.Section != "" {
+			sections = tree.Find(rule.Section)
+		}
+		for _, section := range sections {
+			if !rule.in_scope(section) {
+				continue
+			}
+			failures := rule.check(section)
+			result := ComplianceResult{
+				RuleID:   rule.ID,
+				Severity: rule.Severity,
+				Section:  section.Line,
+				Passed:   len(failures) == 0,
+				Detail:   strings.Join(failures, "; "),
+			}
+			if name, ok := strings.CutPrefix(section.Line, "interface "); ok {
+				result.Interface = normalize_interface_name(name)
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+func (rule ComplianceRule) in_scope(section *ConfigNode) bool {
+	for _, path := range rule.When {
+		if section.First(path) == nil {
+			return false
+		}
+	}
+	for _, path := range rule.Unless {
+		if section.First(path) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns the failed checks of the rule on one section.
+func (rule ComplianceRule) check(section *ConfigNode) []string {
+	var failures []string
+	for _, path := range rule.Require {
+		if section.First(path) == nil {
+			failures = append(failures, "missing "+path)
+		}
+	}
+	for _, path := range rule.Forbid {
+		if found := section.First(path); found != nil {
+			failures = append(failures, "forbidden "+found.Line)
+		}
+	}
+
+	text := section.String()
+	for _, re := range rule.match {
+		if !re.MatchString(text) {
+			failures = append(failures, "no match for "+re.String()[len("(?m)"):])
+		}
+	}
+	for _, re := range rule.forbidMatch {
+		if found := re.FindString(text); found != "" {
+			failures = append(failures, "forbidden "+strings.TrimSpace(found))
+		}
+	}
+	return failures
+}
+
+// Check_compliance evaluates the rules against the latest archived config of the switch and
+// replaces the stored results of the switch with them.
+func (s *Store) Check_compliance(ctx context.Context, switch_id int64, rules *ComplianceRules) ([]ComplianceResult, error) {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT `role` FROM `switches` WHERE `id` = ?", switch_id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading switch %d: %w", switch_id, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("error: switch %d not found", switch_id)
+	}
+	role := Row_string(rows[0], "role")
+
+	version, err := s.Latest_config_version(ctx, switch_id)
+	if err != nil {
+		return nil, err
+	}
+
+	results := rules.Evaluate(role, version.Tree())
+	now := time.Now().UTC()
+	for i := range results {
+		results[i].SwitchID = switch_id
+		results[i].ConfigVersionID = version.ID
+		results[i].CheckedAt = now
+	}
+	if err := s.store_compliance_results(ctx, switch_id, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Check_compliance_all runs Check_compliance on every switch with an archived config.
+// It returns the number of failed results, and the first error after trying every switch.
+func (s *Store) Check_compliance_all(ctx context.Context, rules *ComplianceRules) (int, error) {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT DISTINCT `switch_id` FROM `config_versions`")
+	if err != nil {
+		return 0, fmt.Errorf("error reading config versions: %w", err)
+	}
+
+	failed := 0
+	var firstErr error
+	for _, row := range rows {
+		switch_id := Row_int64(row, "switch_id")
+		results, err := s.Check_compliance(ctx, switch_id, rules)
+		if err != nil {
+			log.Printf("%d :: Check compliance :: %v", switch_id, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, result := range results {
+			if !result.Passed {
+				failed++
+			}
+		}
+	}
+	return failed, firstErr
+}
+
+func (s *Store) store_compliance_results(ctx context.Context, switch_id int64, results []ComplianceResult) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only the latest evaluation of each switch is kept.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `compliance_results` WHERE `switch_id` = ?", switch_id); err != nil {
+		return fmt.Errorf("error deleting compliance results: %w", err)
+	}
+
+	const batch = 1000
+	for start := 0; start < len(results); start += batch {
+		end := min(start+batch, len(results))
+
+		sqlStr := "INSERT INTO `compliance_results` (`switch_id`, `config_version_id`, `rule_id`, `severity`, `section`, `interface`, `passed`, `detail`, `checked_at`) VALUES "
+		var valueStrings []string
+		var valueArgs []any
+		for _, result := range results[start:end] {
+			valueStrings = append(valueStrings, "(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			valueArgs = append(valueArgs, result.SwitchID, result.ConfigVersionID, result.RuleID, result.Severity,
+				result.Section, null_string(result.Interface), result.Passed, result.Detail, result.CheckedAt)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr+strings.Join(valueStrings, ","), valueArgs...); err != nil {
+			return fmt.Errorf("error inserting compliance results: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// check_compliance is the Check_compliance collector, run after Show_running_config
+// when the Store has compliance rules.
+func (s *Store) check_compliance(ctx context.Context, sw Switch) (int64, error) {
+	if s.Compliance == nil {
+		return 0, nil
+	}
+	results, err := s.Check_compliance(ctx, sw.ID, s.Compliance)
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	log.Printf("%d :: %s :: Check compliance :: %d checks, %d failed.\n", sw.ID, sw.Fqdn, len(results), failed)
+	return int64(len(results)), nil
+}
+
+// RuleCompliance summarizes one rule across the fleet.
+type RuleCompliance struct {
+	RuleID   string
+	Severity string
+	Passed   int64
+	Failed   int64
+	// FailingSwitches is the number of switches with at least one failure.
+	FailingSwitches int64
+}
+
+// SwitchCompliance summarizes one switch.
+type SwitchCompliance struct {
+	SwitchID int64
+	Fqdn     string
+	Passed   int64
+	Failed   int64
+}
+
+// ComplianceReport is the fleet compliance built from the stored results.
+type ComplianceReport struct {
+	Rules    []RuleCompliance
+	Switches []SwitchCompliance
+}
+
+// Compliance_report summarizes the stored results by rule and by switch, most failures first.
+func (s *Store) Compliance_report(ctx context.Context) (ComplianceReport, error) {
+	var report ComplianceReport
+
+	rows, err := Return_query_context(ctx, s.DB, "SELECT rule_id, MAX(severity) AS severity, SUM(passed) AS passed, SUM(NOT passed) AS failed, "+
+		"COUNT(DISTINCT CASE WHEN NOT passed THEN switch_id END) AS failing_switches "+
+		"FROM compliance_results GROUP BY rule_id ORDER BY failed DESC, rule_id")
+	if err != nil {
+		return report, fmt.Errorf("error reading compliance results: %w", err)
+	}
+	for _, row := range rows {
+		report.Rules = append(report.Rules, RuleCompliance{
+			RuleID:          Row_string(row, "rule_id"),
+			Severity:        Row_string(row, "severity"),
+			Passed:          Row_int64(row, "passed"),
+			Failed:          Row_int64(row, "failed"),
+			FailingSwitches: Row_int64(row, "failing_switches"),
+		})
+	}
+
+	rows, err = Return_query_context(ctx, s.DB, "SELECT r.switch_id, s.fqdn, SUM(r.passed) AS passed, SUM(NOT r.passed) AS failed "+
+		"FROM compliance_results r JOIN switches s ON s.id = r.switch_id GROUP BY r.switch_id, s.fqdn ORDER BY failed DESC, s.fqdn")
+	if err != nil {
+		return report, fmt.Errorf("error reading compliance results: %w", err)
+	}
+	for _, row := range rows {
+		report.Switches = append(report.Switches, SwitchCompliance{
+			SwitchID: Row_int64(row, "switch_id"),
+			Fqdn:     Row_string(row, "fqdn"),
+			Passed:   Row_int64(row, "passed"),
+			Failed:   Row_int64(row, "failed"),
+		})
+	}
+	return report, nil
+}
+
+// Compliance_failures returns the failed results of a switch, all switches when switch_id is 0.
+func (s *Store) Compliance_failures(ctx context.Context, switch_id int64) ([]ComplianceResult, error) {
+	query := "SELECT switch_id, config_version_id, rule_id, severity, section, interface, detail, checked_at FROM compliance_results WHERE passed = 0"
+	var args []any
+	if switch_id != 0 {
+		query += " AND switch_id = ?"
+		args = append(args, switch_id)
+	}
+	query += " ORDER BY switch_id, rule_id, section"
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error reading compliance results: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []ComplianceResult
+	for rows.Next() {
+		result := ComplianceResult{}
+		var iface sql.NullString
+		if err := rows.Scan(&result.SwitchID, &result.ConfigVersionID, &result.RuleID, &result.Severity, &result.Section, &iface, &result.Detail, &result.CheckedAt); err != nil {
+			return nil, fmt.Errorf("error reading compliance results: %w", err)
+		}
+		result.Interface = iface.String
+		failures = append(failures, result)
+	}
+	return failures, rows.Err()
+}
+
+// Check_compliance runs s.Check_compliance against Default_store.
+func Check_compliance(ctx context.Context, switch_id int64, rules *ComplianceRules) ([]ComplianceResult, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Check_compliance(ctx, switch_id, rules)
+}
+
+// Check_compliance_all runs s.Check_compliance_all against Default_store.
+func Check_compliance_all(ctx context.Context, rules *ComplianceRules) (int, error) {
+	s, err := Default_store()
+	if err != nil {
+		return 0, err
+	}
+	return s.Check_compliance_all(ctx, rules)
+}
+
+// Compliance_report runs s.Compliance_report against Default_store.
+func Compliance_report(ctx context.Context) (ComplianceReport, error) {
+	s, err := Default_store()
+	if err != nil {
+		return ComplianceReport{}, err
+	}
+	return s.Compliance_report(ctx)
+}
+
+// Compliance_failures runs s.Compliance_failures against Default_store.
+func Compliance_failures(ctx context.Context, switch_id int64) ([]ComplianceResult, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Compliance_failures(ctx, switch_id)
+}
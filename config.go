@@ -0,0 +1,269 @@
+package cisco_database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the MySQL connection settings used by DB_connect and every collector.
+// It can be built in code, read from environment variables (Config_from_env)
+// or loaded from a YAML/TOML file (Load_config).
+type Config struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	Database string `yaml:"database" toml:"database"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+
+	// TLS settings. TLS is enabled as soon as TLSCA or TLSCert is set.
+	TLSCA         string `yaml:"tls_ca" toml:"tls_ca"`
+	TLSCert       string `yaml:"tls_cert" toml:"tls_cert"`
+	TLSKey        string `yaml:"tls_key" toml:"tls_key"`
+	TLSServerName string `yaml:"tls_server_name" toml:"tls_server_name"`
+
+	// Timeouts, e.g. "5s" in YAML/TOML files. Zero means the driver default.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+	ReadTimeout    time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout   time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+
+	// Pool sizes. Zero means the database/sql default.
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+
+	// Retention is the policy used by the prune command. Empty means Default_retention_policy.
+	Retention RetentionPolicy `yaml:"retention" toml:"retention"`
+
+	// GitBackup writes the running-configs to a git repository when its Path is set.
+	GitBackup GitBackupConfig `yaml:"git_backup" toml:"git_backup"`
+
+	// Redaction adds rules to the built-in secret redaction applied before configs are stored or exported.
+	Redaction RedactionConfig `yaml:"redaction" toml:"redaction"`
+
+	// ComplianceRules is a YAML/TOML rule file checked against every collected config. Empty disables the check.
+	ComplianceRules string `yaml:"compliance_rules" toml:"compliance_rules"`
+
+	// Retry is the policy for failed device commands. Empty means Default_retry_policy.
+	Retry RetryPolicy `yaml:"retry" toml:"retry"`
+
+	// SingleSession runs every command of a switch over one SSH login instead of one login per command.
+	SingleSession bool `yaml:"single_session" toml:"single_session"`
+
+	// GatewayRoles are the switch roles Show_ip_arp collects the ARP table from. Empty means Default_gateway_roles.
+	GatewayRoles []string `yaml:"gateway_roles" toml:"gateway_roles"`
+
+	// ReverseDNS configures the PTR lookups of Update_fqdn_table.
+	ReverseDNS ReverseDNSConfig `yaml:"reverse_dns" toml:"reverse_dns"`
+
+	// ISE is the MnT API read by Import_ise; its Profiles also filter Import_ise_csv from the command.
+	ISE ISEConfig `yaml:"ise" toml:"ise"`
+}
+
+var (
+	configMu      sync.RWMutex
+	currentConfig *Config
+)
+
+// Default_config returns the settings DB_connect has always used, the local "devices" database,
+// with pool limits suited to a long-lived Store shared by concurrent collectors.
+func Default_config() Config {
+	return Config{
+		Host:            "127.0.0.1",
+		Port:            3306,
+		Database:        "devices",
+		MaxOpenConns:    10,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: 5 * time.Minute,
+	}
+}
+
+// Config_from_env builds a Config from the MYSQL_DATABASE_* environment variables,
+// falling back to Default_config for anything that is not set.
+func Config_from_env() (Config, error) {
+	cfg := Default_config()
+	if err := cfg.apply_env(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Load_config reads a YAML (.yaml/.yml) or TOML (.toml) file into a Config.
+// Environment variables still override the file, so credentials can be kept out of it.
+func Load_config(path string) (Config, error) {
+	cfg := Default_config()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, &cfg)
+	default:
+		return Config{}, fmt.Errorf("unsupported config file format %q (use .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	if err := cfg.apply_env(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Set_config sets the Config used by DB_connect and the package-level collectors.
+// The default Store is closed so the next package-level call reconnects with it.
+func Set_config(cfg Config) {
+	configMu.Lock()
+	currentConfig = &cfg
+	configMu.Unlock()
+
+	reset_default_store()
+}
+
+// Get_config returns the Config set with Set_config. When none was set it loads
+// MYSQL_DATABASE_CONFIG if that variable points to a file, otherwise the environment.
+func Get_config() (Config, error) {
+	configMu.RLock()
+	cfg := currentConfig
+	configMu.RUnlock()
+	if cfg != nil {
+		return *cfg, nil
+	}
+
+	if path := os.Getenv("MYSQL_DATABASE_CONFIG"); path != "" {
+		return Load_config(path)
+	}
+	return Config_from_env()
+}
+
+// apply_env overrides the Config fields whose MYSQL_DATABASE_* variable is set.
+func (cfg *Config) apply_env() error {
+	stringVars := map[string]*string{
+		"MYSQL_DATABASE_HOST":            &cfg.Host,
+		"MYSQL_DATABASE_NAME":            &cfg.Database,
+		"MYSQL_DATABASE_USERNAME":        &cfg.Username,
+		"MYSQL_DATABASE_PASSWORD":        &cfg.Password,
+		"MYSQL_DATABASE_TLS_CA":          &cfg.TLSCA,
+		"MYSQL_DATABASE_TLS_CERT":        &cfg.TLSCert,
+		"MYSQL_DATABASE_TLS_KEY":         &cfg.TLSKey,
+		"MYSQL_DATABASE_TLS_SERVER_NAME": &cfg.TLSServerName,
+	}
+	for name, field := range stringVars {
+		if value := os.Getenv(name); value != "" {
+			*field = value
+		}
+	}
+
+	intVars := map[string]*int{
+		"MYSQL_DATABASE_PORT":           &cfg.Port,
+		"MYSQL_DATABASE_MAX_OPEN_CONNS": &cfg.MaxOpenConns,
+		"MYSQL_DATABASE_MAX_IDLE_CONNS": &cfg.MaxIdleConns,
+	}
+	for name, field := range intVars {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("error: %s must be an integer: %w", name, err)
+			}
+			*field = n
+		}
+	}
+
+	durationVars := map[string]*time.Duration{
+		"MYSQL_DATABASE_CONNECT_TIMEOUT":   &cfg.ConnectTimeout,
+		"MYSQL_DATABASE_READ_TIMEOUT":      &cfg.ReadTimeout,
+		"MYSQL_DATABASE_WRITE_TIMEOUT":     &cfg.WriteTimeout,
+		"MYSQL_DATABASE_CONN_MAX_LIFETIME": &cfg.ConnMaxLifetime,
+	}
+	for name, field := range durationVars {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("error: %s must be a duration like 5s: %w", name, err)
+			}
+			*field = d
+		}
+	}
+
+	return nil
+}
+
+// DSN validates the Config and returns the Data Source Name for the mysql driver.
+// When TLS is configured it registers the TLS settings with the driver.
+func (cfg Config) DSN() (string, error) {
+	if cfg.Username == "" || cfg.Password == "" {
+		return "", fmt.Errorf("error: database username and password must be set (MYSQL_DATABASE_USERNAME and MYSQL_DATABASE_PASSWORD or the config file)")
+	}
+
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.User = cfg.Username
+	mysqlConfig.Passwd = cfg.Password
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	mysqlConfig.DBName = cfg.Database
+	// Use parseTime to handle DATE/DATETIME types correctly.
+	mysqlConfig.ParseTime = true
+	mysqlConfig.Timeout = cfg.ConnectTimeout
+	mysqlConfig.ReadTimeout = cfg.ReadTimeout
+	mysqlConfig.WriteTimeout = cfg.WriteTimeout
+
+	if cfg.TLSCA != "" || cfg.TLSCert != "" {
+		tlsConfig, err := cfg.tls_config()
+		if err != nil {
+			return "", err
+		}
+		name := "cisco_database_" + cfg.Host
+		if err := mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+			return "", fmt.Errorf("error registering TLS config: %w", err)
+		}
+		mysqlConfig.TLSConfig = name
+	}
+
+	return mysqlConfig.FormatDSN(), nil
+}
+
+// tls_config loads the CA and client certificate files named in the Config.
+func (cfg Config) tls_config() (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: cfg.TLSServerName}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = cfg.Host
+	}
+
+	if cfg.TLSCA != "" {
+		pem, err := os.ReadFile(cfg.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("error reading TLS CA %s: %w", cfg.TLSCA, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("error: no certificates found in TLS CA %s", cfg.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading TLS client certificate %s: %w", cfg.TLSCert, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,236 @@
+package cisco_database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ConfigVersion is one distinct running-config of a switch. The same text collected
+// again is not stored twice; LastRunID and LastSeenAt move forward instead.
+type ConfigVersion struct {
+	ID          int64
+	SwitchID    int64
+	Sha256      string
+	Config      string // empty in Config_versions listings, see Config_version
+	Lines       int64
+	FirstRunID  int64
+	LastRunID   int64
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// volatile_config_lines change on every collection without a configuration change,
+// and would give every run a new hash.
+var volatile_config_lines = regexp.MustCompile(`^(Building configuration|Current configuration\s*:|! Last configuration change|! NVRAM config last updated|! No configuration change since|!Time:|!Running configuration last done at|ntp clock-period)`)
+
+// Clean_running_config extracts the "show running-config" output from a terminal session
+// and drops the lines that change without a configuration change. The output ends at the
+// "end" line or at the prompt that echoed the command; banner text is kept verbatim, so a
+// banner line of "#" or ">" characters is not mistaken for the prompt.
+func Clean_running_config(rawOutput string) string {
+	var lines []string
+	prompt := ""
+	parsingActive := false
+	banner := ""
+	for _, line := range strings.Split(rawOutput, "\n") {
+		line = strings.TrimRight(line, "\r ")
+		if !parsingActive {
+			if before, _, found := strings.Cut(line, "show running-config"); found {
+				prompt = strings.TrimSpace(before)
+				parsingActive = true
+			}
+			continue
+		}
+
+		if banner != "" {
+			lines = append(lines, line)
+			if strings.Contains(line, banner) {
+				banner = ""
+			}
+			continue
+		}
+		if prompt != "" && line == prompt {
+			break
+		}
+		if volatile_config_lines.MatchString(line) {
+			continue
+		}
+		lines = append(lines, line)
+		if line == "end" {
+			break
+		}
+		if delimiter, open := banner_delimiter(strings.TrimSpace(line)); open {
+			banner = delimiter
+		}
+	}
+
+	// Keep a single trailing newline so identical configs hash identically.
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
+
+// Archive_config redacts the running-config of a switch and stores it for the run of ctx,
+// deduplicated by the sha256 of the redacted text. It returns the version, whose Config is
+// the redacted text, and whether it was new.
+func (s *Store) Archive_config(ctx context.Context, switch_id int64, config string) (ConfigVersion, bool, error) {
+	config = s.redact(config)
+	sum := sha256.Sum256([]byte(config))
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now().UTC()
+	run_id := run_arg(ctx)
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ConfigVersion{}, false, err
+	}
+	defer tx.Rollback()
+
+	// A no-op update of id makes LastInsertId return the existing row.
+	result, err := tx.ExecContext(ctx, "INSERT INTO `config_versions` (`switch_id`, `sha256`, `config`, `lines`, `first_run_id`, `last_run_id`, `first_seen_at`, `last_seen_at`) VALUES (?, ?, ?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE `id` = LAST_INSERT_ID(`id`), `last_run_id` = VALUES(`last_run_id`), `last_seen_at` = VALUES(`last_seen_at`)",
+		switch_id, hash, config, strings.Count(config, "\n"), run_id, run_id, now, now)
+	if err != nil {
+		return ConfigVersion{}, false, fmt.Errorf("error archiving running-config: %w", err)
+	}
+	version_id, err := result.LastInsertId()
+	if err != nil {
+		return ConfigVersion{}, false, err
+	}
+	// 1 row affected for an insert, 2 for an update of the existing version.
+	affected, _ := result.RowsAffected()
+
+	if run_id != nil {
+		_, err = tx.ExecContext(ctx, "INSERT INTO `config_snapshots` (`switch_id`, `run_id`, `config_version_id`, `collected_at`) VALUES (?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE `config_version_id` = VALUES(`config_version_id`), `collected_at` = VALUES(`collected_at`)",
+			switch_id, run_id, version_id, now)
+		if err != nil {
+			return ConfigVersion{}, false, fmt.Errorf("error archiving running-config snapshot: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ConfigVersion{}, false, err
+	}
+
+	version, err := s.Config_version(ctx, version_id)
+	return version, affected == 1, err
+}
+
+// Config_versions returns the distinct running-configs of a switch, oldest first, without their text.
+func (s *Store) Config_versions(ctx context.Context, switch_id int64) ([]ConfigVersion, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT `id`, `switch_id`, `sha256`, `lines`, `first_run_id`, `last_run_id`, `first_seen_at`, `last_seen_at` FROM `config_versions` WHERE `switch_id` = ? ORDER BY `first_seen_at`, `id`", switch_id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []ConfigVersion
+	for rows.Next() {
+		var version ConfigVersion
+		if err := scan_config_version(rows, &version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// Config_version returns one running-config version with its text.
+func (s *Store) Config_version(ctx context.Context, version_id int64) (ConfigVersion, error) {
+	var version ConfigVersion
+	row := s.DB.QueryRowContext(ctx, "SELECT `id`, `switch_id`, `sha256`, `lines`, `first_run_id`, `last_run_id`, `first_seen_at`, `last_seen_at`, `config` FROM `config_versions` WHERE `id` = ?", version_id)
+	if err := scan_config_version(row, &version, &version.Config); err != nil {
+		return ConfigVersion{}, err
+	}
+	return version, nil
+}
+
+// Latest_config_version returns the running-config of the switch seen most recently.
+func (s *Store) Latest_config_version(ctx context.Context, switch_id int64) (ConfigVersion, error) {
+	var version_id int64
+	err := s.DB.QueryRowContext(ctx, "SELECT `id` FROM `config_versions` WHERE `switch_id` = ? ORDER BY `last_seen_at` DESC, `id` DESC LIMIT 1", switch_id).Scan(&version_id)
+	if err != nil {
+		return ConfigVersion{}, fmt.Errorf("error reading latest config version of switch %d: %w", switch_id, err)
+	}
+	return s.Config_version(ctx, version_id)
+}
+
+func scan_config_version(row interface{ Scan(...any) error }, version *ConfigVersion, extra ...any) error {
+	var firstRun, lastRun sql.NullInt64
+	dest := append([]any{&version.ID, &version.SwitchID, &version.Sha256, &version.Lines, &firstRun, &lastRun, &version.FirstSeenAt, &version.LastSeenAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return fmt.Errorf("error reading config version: %w", err)
+	}
+	version.FirstRunID = firstRun.Int64
+	version.LastRunID = lastRun.Int64
+	return nil
+}
+
+// Config_diff returns the unified diff between two running-config versions.
+func (s *Store) Config_diff(ctx context.Context, from_id, to_id int64) (string, error) {
+	from, err := s.Config_version(ctx, from_id)
+	if err != nil {
+		return "", err
+	}
+	to, err := s.Config_version(ctx, to_id)
+	if err != nil {
+		return "", err
+	}
+	return Unified_config_diff(from, to)
+}
+
+// Unified_config_diff returns the unified diff between two versions, with 3 lines of context.
+func Unified_config_diff(from, to ConfigVersion) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from.Config),
+		B:        difflib.SplitLines(to.Config),
+		FromFile: fmt.Sprintf("version %d", from.ID),
+		FromDate: from.FirstSeenAt.Format(time.RFC3339),
+		ToFile:   fmt.Sprintf("version %d", to.ID),
+		ToDate:   to.FirstSeenAt.Format(time.RFC3339),
+		Context:  3,
+	})
+}
+
+// Config_versions runs s.Config_versions against Default_store.
+func Config_versions(ctx context.Context, switch_id int64) ([]ConfigVersion, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Config_versions(ctx, switch_id)
+}
+
+// Config_diff runs s.Config_diff against Default_store.
+func Config_diff(ctx context.Context, from_id, to_id int64) (string, error) {
+	s, err := Default_store()
+	if err != nil {
+		return "", err
+	}
+	return s.Config_diff(ctx, from_id, to_id)
+}
+
+// Config_version runs s.Config_version against Default_store.
+func Config_version(ctx context.Context, version_id int64) (ConfigVersion, error) {
+	s, err := Default_store()
+	if err != nil {
+		return ConfigVersion{}, err
+	}
+	return s.Config_version(ctx, version_id)
+}
+
+// Latest_config_version runs s.Latest_config_version against Default_store.
+func Latest_config_version(ctx context.Context, switch_id int64) (ConfigVersion, error) {
+	s, err := Default_store()
+	if err != nil {
+		return ConfigVersion{}, err
+	}
+	return s.Latest_config_version(ctx, switch_id)
+}
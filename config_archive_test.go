@@ -0,0 +1,77 @@
+package cisco_database
+
+import "testing"
+
+func TestCleanRunningConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "banner of prompt characters",
+			raw: "sw1#show running-config\r\n" +
+				"Building configuration...\r\n" +
+				"\r\n" +
+				"Current configuration : 1234 bytes\r\n" +
+				"!\r\n" +
+				"! Last configuration change at 10:00:00 UTC Mon Oct 12 2026\r\n" +
+				"hostname sw1\r\n" +
+				"!\r\n" +
+				"banner motd ^C\r\n" +
+				"#################\r\n" +
+				"-->\r\n" +
+				"sw1#\r\n" +
+				"end\r\n" +
+				"^C\r\n" +
+				"!\r\n" +
+				"line vty 0 4\r\n" +
+				" password 7 0822455D0A16\r\n" +
+				" transport input ssh\r\n" +
+				"!\r\n" +
+				"end\r\n" +
+				"\r\n" +
+				"sw1#",
+			want: "!\nhostname sw1\n!\nbanner motd ^C\n#################\n-->\nsw1#\nend\n^C\n!\nline vty 0 4\n password 7 0822455D0A16\n transport input ssh\n!\nend\n",
+		},
+		{
+			name: "single line banner",
+			raw: "sw1#show running-config\n" +
+				"hostname sw1\n" +
+				"banner login #Authorized access only#\n" +
+				"line vty 0 4\n" +
+				"end\n" +
+				"sw1#",
+			want: "hostname sw1\nbanner login #Authorized access only#\nline vty 0 4\nend\n",
+		},
+		{
+			name: "nx-os ends at the prompt",
+			raw: "nx1# show running-config\n" +
+				"!Command: show running-config\n" +
+				"!Running configuration last done at: Mon Oct 12 10:00:00 2026\n" +
+				"!Time: Mon Oct 12 10:05:00 2026\n" +
+				"version 9.3(8) Bios:version 05.45\n" +
+				"hostname nx1\n" +
+				"line vty\n" +
+				"\n" +
+				"nx1#",
+			want: "!Command: show running-config\nversion 9.3(8) Bios:version 05.45\nhostname nx1\nline vty\n",
+		},
+		{
+			name: "lines ending in # or > are config",
+			raw: "sw1#show running-config\n" +
+				"hostname sw1\n" +
+				"alias exec top#\n" +
+				"description uplink->\n" +
+				"end\n",
+			want: "hostname sw1\nalias exec top#\ndescription uplink->\nend\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Clean_running_config(tt.raw); got != tt.want {
+				t.Errorf("Clean_running_config =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
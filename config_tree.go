@@ -0,0 +1,184 @@
+package cisco_database
+
+import (
+	"strings"
+)
+
+// ConfigNode is one command of a running-config with the commands indented under it,
+// e.g. "interface GigabitEthernet1/0/1" with "switchport access vlan 10" as a child.
+// The root returned by Parse_config_tree has an empty Line and the global commands as children.
+type ConfigNode struct {
+	// Line is the command without its indentation.
+	Line     string
+	Children []*ConfigNode
+	Parent   *ConfigNode
+	indent   int
+}
+
+// Parse_config_tree turns a running-config (IOS, IOS-XE or NX-OS) into a tree of sections
+// using the indentation of each line. "!" comment lines and blank lines are skipped,
+// banner text is kept verbatim under its banner command.
+func Parse_config_tree(config string) *ConfigNode {
+	root := &ConfigNode{indent: -1}
+	current := root
+
+	lines := strings.Split(strings.ReplaceAll(config, "\r", ""), "\n")
+	for i := 0; i < len(lines); i++ {
+		raw := strings.TrimRight(lines[i], " \t")
+		line := strings.TrimLeft(raw, " \t")
+		if line == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+		indent := len(raw) - len(line)
+
+		for current != root && current.indent >= indent {
+			current = current.Parent
+		}
+		node := &ConfigNode{Line: line, Parent: current, indent: indent}
+		current.Children = append(current.Children, node)
+		current = node
+
+		if delimiter, open := banner_delimiter(line); open {
+			i = node.read_banner(lines, i+1, delimiter)
+			current = node.Parent
+		}
+	}
+	return root
+}
+
+// banner_delimiter returns the delimiter of a "banner <type> ^C..." command and whether
+// the banner text continues on the next lines.
+func banner_delimiter(line string) (string, bool) {
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) < 3 || fields[0] != "banner" || fields[2] == "" {
+		return "", false
+	}
+	text := fields[2]
+	// show running-config prints the Ctrl-C delimiter as "^C".
+	delimiter := text[:1]
+	if strings.HasPrefix(text, "^") && len(text) > 1 {
+		delimiter = text[:2]
+	}
+	return delimiter, !strings.Contains(text[len(delimiter):], delimiter)
+}
+
+// read_banner adds the banner lines starting at lines[start] as children of node, up to the
+// line holding the closing delimiter, and returns the index of that line.
+func (node *ConfigNode) read_banner(lines []string, start int, delimiter string) int {
+	for i := start; i < len(lines); i++ {
+		text := strings.TrimRight(lines[i], "\r")
+		node.Children = append(node.Children, &ConfigNode{Line: text, Parent: node, indent: node.indent + 1})
+		if strings.Contains(text, delimiter) {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// Find returns the nodes matching path, a list of commands separated by ">", each matched
+// against one level of the tree, e.g. "interface GigabitEthernet1/0/1 > switchport access vlan".
+// A command matches the lines starting with its words, a "*" word matches any word
+// (e.g. "interface * > shutdown"), and interface names may be given in their long or short form (Gi1/0/1).
+func (node *ConfigNode) Find(path string) []*ConfigNode {
+	var segments []string
+	for _, segment := range strings.Split(path, ">") {
+		segments = append(segments, strings.Join(strings.Fields(segment), " "))
+	}
+
+	nodes := []*ConfigNode{node}
+	for _, segment := range segments {
+		var next []*ConfigNode
+		for _, n := range nodes {
+			for _, child := range n.Children {
+				if config_line_matches(child.Line, segment) {
+					next = append(next, child)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// First returns the first node matching path, nil when none does.
+func (node *ConfigNode) First(path string) *ConfigNode {
+	if nodes := node.Find(path); len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+// Value returns the rest of the first line matching path after the last command of the path,
+// e.g. "10" for "interface Gi1/0/1 > switchport access vlan".
+func (node *ConfigNode) Value(path string) (string, bool) {
+	found := node.First(path)
+	if found == nil {
+		return "", false
+	}
+	segments := strings.Split(path, ">")
+	last := strings.Fields(segments[len(segments)-1])
+	fields := strings.Fields(found.Line)
+	if len(last) > len(fields) {
+		return "", true
+	}
+	return strings.Join(fields[len(last):], " "), true
+}
+
+// Lines returns the line of the node followed by the lines of its descendants, without indentation.
+func (node *ConfigNode) Lines() []string {
+	var lines []string
+	if node.Line != "" {
+		lines = append(lines, node.Line)
+	}
+	for _, child := range node.Children {
+		lines = append(lines, child.Lines()...)
+	}
+	return lines
+}
+
+// String renders the node and its descendants with one space of indentation per level.
+func (node *ConfigNode) String() string {
+	var b strings.Builder
+	node.write(&b, 0)
+	return b.String()
+}
+
+func (node *ConfigNode) write(b *strings.Builder, depth int) {
+	if node.Line != "" {
+		b.WriteString(strings.Repeat(" ", depth))
+		b.WriteString(node.Line)
+		b.WriteString("\n")
+		depth++
+	}
+	for _, child := range node.Children {
+		child.write(b, depth)
+	}
+}
+
+// config_line_matches reports whether a config line matches one segment of a Find path.
+func config_line_matches(line, segment string) bool {
+	words := strings.Fields(segment)
+	fields := strings.Fields(line)
+	if len(words) <= len(fields) {
+		matched := true
+		for i, word := range words {
+			if word != "*" && word != fields[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	// "interface GigabitEthernet1/0/1" also matches "interface Gi1/0/1".
+	name, isInterface := strings.CutPrefix(segment, "interface ")
+	lineName, lineIsInterface := strings.CutPrefix(strings.Join(fields, " "), "interface ")
+	return isInterface && lineIsInterface && normalize_interface_name(name) == normalize_interface_name(lineName)
+}
+
+// Tree parses the version text, see Parse_config_tree. Config_versions listings have no text.
+func (version ConfigVersion) Tree() *ConfigNode {
+	return Parse_config_tree(version.Config)
+}
@@ -0,0 +1,140 @@
+package cisco_database
+
+import (
+	"slices"
+	"testing"
+)
+
+const config_tree_fixture = `!
+hostname sw1
+!
+vrf definition MGMT
+ address-family ipv4
+  route-target export 65000:1
+ exit-address-family
+!
+interface GigabitEthernet1/0/1
+ description Desk 1-01
+ switchport access vlan 10
+ switchport mode access
+ spanning-tree portfast
+!
+interface GigabitEthernet1/0/2
+ switchport access vlan 20
+ shutdown
+!
+interface Vlan10
+ vrf forwarding MGMT
+ ip address 10.0.10.1 255.255.255.0
+!
+banner motd ^C
+#################
+  interface Gi9/9/9
+ shutdown
+-->
+^C
+banner login #Authorized access only#
+!
+line vty 0 4
+ transport input ssh
+line vty 5 15
+ transport input none
+!
+end
+`
+
+func TestParseConfigTree(t *testing.T) {
+	root := Parse_config_tree(config_tree_fixture)
+
+	var top []string
+	for _, child := range root.Children {
+		top = append(top, child.Line)
+	}
+	want := []string{
+		"hostname sw1",
+		"vrf definition MGMT",
+		"interface GigabitEthernet1/0/1",
+		"interface GigabitEthernet1/0/2",
+		"interface Vlan10",
+		"banner motd ^C",
+		"banner login #Authorized access only#",
+		"line vty 0 4",
+		"line vty 5 15",
+		"end",
+	}
+	if !slices.Equal(top, want) {
+		t.Errorf("top level =\n%q\nwant\n%q", top, want)
+	}
+
+	// The banner text stays verbatim under the banner, its "interface" and "shutdown" lines are not commands.
+	banner := root.First("banner motd")
+	wantBanner := []string{"#################", "  interface Gi9/9/9", " shutdown", "-->", "^C"}
+	var gotBanner []string
+	for _, child := range banner.Children {
+		gotBanner = append(gotBanner, child.Line)
+	}
+	if !slices.Equal(gotBanner, wantBanner) {
+		t.Errorf("banner motd =\n%q\nwant\n%q", gotBanner, wantBanner)
+	}
+	if single := root.First("banner login"); len(single.Children) != 0 {
+		t.Errorf("single line banner has children %q", single.Lines())
+	}
+}
+
+func TestConfigTreeFind(t *testing.T) {
+	root := Parse_config_tree(config_tree_fixture)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"hostname", []string{"hostname sw1"}},
+		{"vrf definition MGMT > address-family ipv4 > route-target export", []string{"route-target export 65000:1"}},
+		{"interface * > shutdown", []string{"shutdown"}},
+		{"interface * > switchport access vlan", []string{"switchport access vlan 10", "switchport access vlan 20"}},
+		{"interface Gi1/0/1 > description", []string{"description Desk 1-01"}},
+		{"interface GigabitEthernet1/0/2 > switchport access vlan", []string{"switchport access vlan 20"}},
+		{"line vty * * > transport input", []string{"transport input ssh", "transport input none"}},
+		{"interface Gi9/9/9", nil},
+		{"interface * > vrf forwarding", []string{"vrf forwarding MGMT"}},
+		{"router bgp", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, node := range root.Find(tt.path) {
+			got = append(got, node.Line)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Find(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	// The matched node keeps its parent, e.g. the interface of a "shutdown".
+	if shut := root.First("interface * > shutdown"); shut == nil || shut.Parent.Line != "interface GigabitEthernet1/0/2" {
+		t.Errorf("First(interface * > shutdown) parent = %v", shut)
+	}
+}
+
+func TestConfigTreeValue(t *testing.T) {
+	root := Parse_config_tree(config_tree_fixture)
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOk bool
+	}{
+		{"hostname", "sw1", true},
+		{"interface Gi1/0/1 > switchport access vlan", "10", true},
+		{"interface Vlan10 > ip address", "10.0.10.1 255.255.255.0", true},
+		{"interface Gi1/0/1 > spanning-tree portfast", "", true},
+		{"interface Gi1/0/1 > shutdown", "", false},
+		{"interface Gi1/0/3 > switchport access vlan", "", false},
+		{"snmp-server community", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := root.Value(tt.path)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("Value(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cisco_database
+
+import (
+	"context"
+	"time"
+)
+
+// run_context runs fn in a goroutine and returns early with ctx.Err() when ctx is done first.
+// The cisco and akips packages do not accept a context, so an abandoned call
+// keeps running in the background until its own timeout.
+func run_context[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// sleep_context pauses for d or until ctx is done.
+func sleep_context(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
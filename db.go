@@ -1,37 +1,56 @@
 package cisco_database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
+	"strconv"
 	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
-// db_connect handles the database connection setup.
-// It reads credentials from environment variables and returns a database handle.
+// DB_connect handles the database connection setup.
+// It uses the Config set with Set_config (or the environment) and returns a database handle.
 func DB_connect() (*sql.DB, error) {
-	// --- 1. Get Credentials from Environment Variables ---
-	username := os.Getenv("MYSQL_DATABASE_USERNAME")
-	password := os.Getenv("MYSQL_DATABASE_PASSWORD")
-
-	if username == "" || password == "" {
-		return nil, fmt.Errorf("error: MYSQL_DATABASE_USERNAME and MYSQL_DATABASE_PASSWORD environment variables must be set")
+	cfg, err := Get_config()
+	if err != nil {
+		return nil, err
 	}
+	return DB_connect_config(cfg)
+}
 
-	// --- 2. Construct the Data Source Name (DSN) ---
-	dbName := "devices"
-	// Use ?parseTime=true to handle DATE/DATETIME types correctly.
-	dsn := fmt.Sprintf("%s:%s@tcp(127.0.0.1:3306)/%s?parseTime=true", username, password, dbName)
+// DB_connect_config opens and verifies a database connection using the given Config.
+func DB_connect_config(cfg Config) (*sql.DB, error) {
+	return DB_connect_config_context(context.Background(), cfg)
+}
 
-	// --- 3. Open and Verify the Connection ---
+// DB_connect_config_context is DB_connect_config with a context bounding the initial ping.
+func DB_connect_config_context(ctx context.Context, cfg Config) (*sql.DB, error) {
+	// --- 1. Construct the Data Source Name (DSN) ---
+	dsn, err := cfg.DSN()
+	if err != nil {
+		return nil, err
+	}
+
+	// --- 2. Open and Verify the Connection ---
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error preparing database connection: %w", err)
 	}
 
-	err = db.Ping()
+	// --- 3. Apply the pool settings ---
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	err = db.PingContext(ctx)
 	if err != nil {
 		db.Close() // Close the connection if ping fails
 		return nil, fmt.Errorf("error connecting to the database: %w", err)
@@ -43,9 +62,15 @@ func DB_connect() (*sql.DB, error) {
 // Execute_query runs a SQL statement that does not return rows (e.g., INSERT, UPDATE, DELETE).
 // For an INSERT, it returns the last inserted ID.
 // For UPDATE or DELETE, it returns the number of rows affected.
-func Execute_query(db *sql.DB, query string) (int64, error) {
+// Values must be passed as args and referenced with ? placeholders in the query.
+func Execute_query(db *sql.DB, query string, args ...any) (int64, error) {
+	return Execute_query_context(context.Background(), db, query, args...)
+}
+
+// Execute_query_context is Execute_query with a context for deadlines and cancellation.
+func Execute_query_context(ctx context.Context, db *sql.DB, query string, args ...any) (int64, error) {
 	// Execute the query
-	result, err := db.Exec(query)
+	result, err := db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return 0, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -70,8 +95,14 @@ func Execute_query(db *sql.DB, query string) (int64, error) {
 
 // Return_query executes a SELECT statement and returns the results dynamically.
 // It returns a slice of maps, where each map represents a row (column_name -> value).
-func Return_query(db *sql.DB, query string) ([]map[string]interface{}, error) {
-	rows, err := db.Query(query)
+// Values must be passed as args and referenced with ? placeholders in the query.
+func Return_query(db *sql.DB, query string, args ...any) ([]map[string]interface{}, error) {
+	return Return_query_context(context.Background(), db, query, args...)
+}
+
+// Return_query_context is Return_query with a context for deadlines and cancellation.
+func Return_query_context(ctx context.Context, db *sql.DB, query string, args ...any) ([]map[string]interface{}, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -123,3 +154,37 @@ func Return_query(db *sql.DB, query string) ([]map[string]interface{}, error) {
 
 	return results, nil
 }
+
+// Row_int64 reads an integer column from a Return_query row.
+// It returns 0 when the column is missing or NULL.
+func Row_int64(row map[string]interface{}, column string) int64 {
+	switch v := row[column].(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// Row_string reads a text column from a Return_query row.
+// It returns "" when the column is missing or NULL.
+func Row_string(row map[string]interface{}, column string) string {
+	switch v := row[column].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,147 @@
+package cisco_database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// GitBackupConfig enables the git backups of the running-configs.
+type GitBackupConfig struct {
+	// Path is the directory of the git repository, created when missing. Empty disables the backups.
+	Path string `yaml:"path" toml:"path"`
+	// AuthorName and AuthorEmail sign the commits. Default "cisco_database".
+	AuthorName  string `yaml:"author_name" toml:"author_name"`
+	AuthorEmail string `yaml:"author_email" toml:"author_email"`
+}
+
+// GitExporter writes the running-config of every switch to <fqdn>.cfg in a local git
+// repository, and commits the files of a collection run once the run is finished.
+// It uses go-git, no git binary is needed.
+type GitExporter struct {
+	Path        string
+	AuthorName  string
+	AuthorEmail string
+
+	mu   sync.Mutex
+	repo *git.Repository
+	// pending holds the files written by each unfinished run, file name to fqdn.
+	pending map[int64]map[string]string
+}
+
+// New_git_exporter opens the repository at cfg.Path, initializing it when needed.
+func New_git_exporter(cfg GitBackupConfig) (*GitExporter, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("error: git backup path is empty")
+	}
+	if cfg.AuthorName == "" {
+		cfg.AuthorName = "cisco_database"
+	}
+	if cfg.AuthorEmail == "" {
+		cfg.AuthorEmail = "cisco_database@localhost"
+	}
+
+	repo, err := git.PlainOpen(cfg.Path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+			return nil, fmt.Errorf("error creating git backup directory %s: %w", cfg.Path, err)
+		}
+		repo, err = git.PlainInit(cfg.Path, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening git backup repository %s: %w", cfg.Path, err)
+	}
+
+	return &GitExporter{
+		Path:        cfg.Path,
+		AuthorName:  cfg.AuthorName,
+		AuthorEmail: cfg.AuthorEmail,
+		repo:        repo,
+		pending:     make(map[int64]map[string]string),
+	}, nil
+}
+
+// Write saves the config of a switch to <fqdn>.cfg for the given run.
+// Nothing is committed until Commit is called for the run.
+func (e *GitExporter) Write(run_id int64, fqdn string, config string) error {
+	file := fqdn + ".cfg"
+	if fqdn == "" || fqdn == "." || fqdn == ".." || strings.ContainsAny(fqdn, `/\`) {
+		return fmt.Errorf("error: invalid switch name %q for a git backup file", fqdn)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	full := filepath.Join(e.Path, file)
+	if current, err := os.ReadFile(full); err != nil || !bytes.Equal(current, []byte(config)) {
+		if err := os.WriteFile(full, []byte(config), 0o644); err != nil {
+			return fmt.Errorf("error writing git backup file %s: %w", full, err)
+		}
+	}
+
+	// The file is listed even when unchanged on disk, a failed commit is retried by the next run.
+	if e.pending[run_id] == nil {
+		e.pending[run_id] = make(map[string]string)
+	}
+	e.pending[run_id][file] = fqdn
+	return nil
+}
+
+// Commit commits the files written for the run whose content changed, with a message listing
+// the changed switches. It returns the commit hash, or "" when nothing changed.
+func (e *GitExporter) Commit(run_id int64) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	files := e.pending[run_id]
+	delete(e.pending, run_id)
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	worktree, err := e.repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("error opening git backup worktree: %w", err)
+	}
+	for file := range files {
+		if _, err := worktree.Add(file); err != nil {
+			return "", fmt.Errorf("error staging git backup file %s: %w", file, err)
+		}
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return "", fmt.Errorf("error reading git backup status: %w", err)
+	}
+	var changed []string
+	for file, fqdn := range files {
+		if code := status.File(file).Staging; code != git.Unmodified && code != git.Untracked {
+			changed = append(changed, fqdn)
+		}
+	}
+	if len(changed) == 0 {
+		return "", nil
+	}
+	sort.Strings(changed)
+
+	message := fmt.Sprintf("Run %d: %d switch configs changed\n\n%s\n", run_id, len(changed), strings.Join(changed, "\n"))
+	hash, err := worktree.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: e.AuthorName, Email: e.AuthorEmail, When: time.Now()},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error committing git backup: %w", err)
+	}
+
+	log.Printf("Git backup :: run %d :: %d switch configs committed as %s", run_id, len(changed), hash.String()[:12])
+	return hash.String(), nil
+}
@@ -0,0 +1,84 @@
+package cisco_database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/xtokio/cisco"
+)
+
+// InterfaceCounters holds the numeric values of a "show interfaces" entry,
+// stored in the typed interfaces columns next to the raw strings.
+// A field is invalid (NULL) when the raw value is missing or cannot be parsed.
+type InterfaceCounters struct {
+	MtuBytes      sql.NullInt64
+	BandwidthKbit sql.NullInt64
+	DelayUsec     sql.NullInt64
+	// Reliability and loads are reported as x/255, stored as a fraction between 0 and 1.
+	Reliability   sql.NullFloat64
+	TxLoad        sql.NullFloat64
+	RxLoad        sql.NullFloat64
+	InputRateBps  sql.NullInt64
+	OutputRateBps sql.NullInt64
+	InputPackets  sql.NullInt64
+	OutputPackets sql.NullInt64
+	InputBytes    sql.NullInt64
+	OutputBytes   sql.NullInt64
+	Runts         sql.NullInt64
+	Giants        sql.NullInt64
+	Throttles     sql.NullInt64
+	InputErrors   sql.NullInt64
+	OutputErrors  sql.NullInt64
+	CrcErrors     sql.NullInt64
+	Collisions    sql.NullInt64
+}
+
+// Parse_interface_counters converts the string fields of a cisco.InterfaceDetails.
+func Parse_interface_counters(details cisco.InterfaceDetails) InterfaceCounters {
+	return InterfaceCounters{
+		MtuBytes:      parse_count(details.Mtu),
+		BandwidthKbit: parse_count(details.Bandwidth),
+		DelayUsec:     parse_count(details.Delay),
+		Reliability:   parse_fraction(details.Reliability),
+		TxLoad:        parse_fraction(details.TxLoad),
+		RxLoad:        parse_fraction(details.RxLoad),
+		InputRateBps:  parse_count(details.InputRateBps),
+		OutputRateBps: parse_count(details.OutputRateBps),
+		InputPackets:  parse_count(details.PacketsInput),
+		OutputPackets: parse_count(details.PacketsOutput),
+		InputBytes:    parse_count(details.BytesInput),
+		OutputBytes:   parse_count(details.BytesOutput),
+		Runts:         parse_count(details.Runts),
+		Giants:        parse_count(details.Giants),
+		Throttles:     parse_count(details.Throttles),
+		InputErrors:   parse_count(details.InputErrors),
+		OutputErrors:  parse_count(details.OutputErrors),
+		CrcErrors:     parse_count(details.CrcErrors),
+		Collisions:    parse_count(details.Collisions),
+	}
+}
+
+// parse_count parses a non-negative integer such as "1500" or "1,234".
+func parse_count(raw string) sql.NullInt64 {
+	raw = strings.ReplaceAll(strings.TrimSpace(raw), ",", "")
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n < 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: n, Valid: true}
+}
+
+// parse_fraction parses "x/y" such as "255/255" into x divided by y.
+func parse_fraction(raw string) sql.NullFloat64 {
+	numerator, denominator, found := strings.Cut(strings.TrimSpace(raw), "/")
+	if !found {
+		return sql.NullFloat64{}
+	}
+	x, errX := strconv.ParseFloat(numerator, 64)
+	y, errY := strconv.ParseFloat(denominator, 64)
+	if errX != nil || errY != nil || y <= 0 {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: x / y, Valid: true}
+}
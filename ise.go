@@ -0,0 +1,487 @@
+package cisco_database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ISEConfig configures the Cisco ISE monitoring (MnT) API read by Import_ise.
+type ISEConfig struct {
+	// URL is the MnT node, "https://ise.example.com". Empty disables the API import.
+	URL      string `yaml:"url" toml:"url"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	// TLSCA is a PEM file with the CA of the ISE certificate. Empty uses the system pool.
+	TLSCA string `yaml:"tls_ca" toml:"tls_ca"`
+	// InsecureSkipVerify disables the certificate check, for lab nodes with a self-signed certificate.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
+	// Timeout bounds one request. Default 30s.
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// Concurrency is the number of session requests in flight. Default 8.
+	Concurrency int `yaml:"concurrency" toml:"concurrency"`
+	// Profiles keeps the endpoints whose profile contains one of these, e.g. "Cisco-IP-Phone". Empty keeps all.
+	Profiles []string `yaml:"profiles" toml:"profiles"`
+}
+
+// IseEndpoint is one endpoint authenticated by ISE and the switch port it was seen on.
+type IseEndpoint struct {
+	// MacAddress is in the switch notation, 0050.56a3.1234.
+	MacAddress string
+	Profile    string
+	// SwitchIPAddress is the NAS IP address the switch authenticated from.
+	SwitchIPAddress string
+	// Interface is the NAS port in the short form of the interfaces table, Gi1/0/1.
+	Interface  string
+	LastChange string
+}
+
+// ISEClient reads the active sessions of the ISE MnT API. It is safe for concurrent use.
+type ISEClient struct {
+	base        *url.URL
+	username    string
+	password    string
+	client      *http.Client
+	concurrency int
+	profiles    []string
+}
+
+// New_ise_client returns an ISEClient for cfg, with the defaults for the unset fields.
+func New_ise_client(cfg ISEConfig) (*ISEClient, error) {
+	base, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("error: invalid ISE url %q", cfg.URL)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.TLSCA != "" {
+		pem, err := os.ReadFile(cfg.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ISE TLS CA %s: %w", cfg.TLSCA, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("error: no certificates found in ISE TLS CA %s", cfg.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	c := &ISEClient{
+		base:        base,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		client:      &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		concurrency: cfg.Concurrency,
+		profiles:    cfg.Profiles,
+	}
+	if c.client.Timeout <= 0 {
+		c.client.Timeout = 30 * time.Second
+	}
+	if c.concurrency <= 0 {
+		c.concurrency = 8
+	}
+	return c, nil
+}
+
+// errIseNotFound is returned by get for a 404, a session that ended between two requests.
+var errIseNotFound = errors.New("not found")
+
+// get decodes the XML document at path into v.
+func (c *ISEClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base.String()+path, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/xml")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error querying ISE %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errIseNotFound
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if detail := strings.TrimSpace(string(body)); detail != "" {
+			return fmt.Errorf("error querying ISE %s: %s: %s", path, resp.Status, detail)
+		}
+		return fmt.Errorf("error querying ISE %s: %s", path, resp.Status)
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding ISE %s: %w", path, err)
+	}
+	return nil
+}
+
+// Active_sessions returns the MAC addresses (calling station ids) of the active sessions.
+func (c *ISEClient) Active_sessions(ctx context.Context) ([]string, error) {
+	var list struct {
+		Sessions []struct {
+			CallingStationID string `xml:"calling_station_id"`
+		} `xml:"activeSession"`
+	}
+	if err := c.get(ctx, "/admin/API/mnt/Session/ActiveList", &list); err != nil {
+		return nil, err
+	}
+
+	var macs []string
+	seen := make(map[string]bool)
+	for _, session := range list.Sessions {
+		mac := strings.TrimSpace(session.CallingStationID)
+		if mac == "" || seen[mac] {
+			continue
+		}
+		seen[mac] = true
+		macs = append(macs, mac)
+	}
+	return macs, nil
+}
+
+// Session returns the endpoint of the latest session of mac. ok is false when ISE has no session for it.
+func (c *ISEClient) Session(ctx context.Context, mac string) (endpoint IseEndpoint, ok bool, err error) {
+	var session struct {
+		CallingStationID string `xml:"calling_station_id"`
+		NasIPAddress     string `xml:"nas_ip_address"`
+		NasPortID        string `xml:"nas_port_id"`
+		EndpointPolicy   string `xml:"endpoint_policy"`
+		AuthAcsTimestamp string `xml:"auth_acs_timestamp"`
+		AcsTimestamp     string `xml:"acs_timestamp"`
+	}
+	err = c.get(ctx, "/admin/API/mnt/Session/MACAddress/"+url.PathEscape(mac), &session)
+	if errors.Is(err, errIseNotFound) {
+		return endpoint, false, nil
+	}
+	if err != nil {
+		return endpoint, false, err
+	}
+
+	if session.CallingStationID == "" {
+		session.CallingStationID = mac
+	}
+	endpoint, ok = new_ise_endpoint(session.CallingStationID, session.EndpointPolicy, session.NasIPAddress, session.NasPortID,
+		first_non_empty(session.AuthAcsTimestamp, session.AcsTimestamp))
+	return endpoint, ok, nil
+}
+
+// Endpoints returns the endpoints of every active session whose profile is kept by the client.
+// Sessions whose request failed are skipped and reported in the error map.
+func (c *ISEClient) Endpoints(ctx context.Context) ([]IseEndpoint, map[string]error, error) {
+	macs, err := c.Active_sessions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var endpoints []IseEndpoint
+	failed := make(map[string]error)
+	var mu sync.Mutex
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range min(c.concurrency, max(len(macs), 1)) {
+		wg.Go(func() {
+			for mac := range jobs {
+				endpoint, ok, err := c.Session(ctx, mac)
+				mu.Lock()
+				switch {
+				case err != nil:
+					failed[mac] = err
+				case ok && ise_profile_kept(endpoint.Profile, c.profiles):
+					endpoints = append(endpoints, endpoint)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+
+feed:
+	for _, mac := range macs {
+		select {
+		case jobs <- mac:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return endpoints, failed, nil
+}
+
+// ise_csv_columns maps the normalized headers of the ISE endpoint and session exports to IseEndpoint fields.
+var ise_csv_columns = map[string]string{
+	"macaddress":       "mac",
+	"callingstationid": "mac",
+	"endpointid":       "mac",
+	"endpointpolicy":   "profile",
+	"endpointprofile":  "profile",
+	"profile":          "profile",
+	"nasipaddress":     "switch",
+	"deviceipaddress":  "switch",
+	"networkdeviceip":  "switch",
+	"switchipaddress":  "switch",
+	"nasportid":        "interface",
+	"nasport":          "interface",
+	"interface":        "interface",
+	"updatetime":       "last_change",
+	"updated":          "last_change",
+	"lastupdate":       "last_change",
+	"authacstimestamp": "last_change",
+	"lastchange":       "last_change",
+}
+
+// Parse_ise_csv reads an endpoint export of ISE. The columns are found by their header,
+// MACAddress or calling_station_id, EndPointPolicy, NAS IP Address, NAS Port Id, UpdateTime and their variants;
+// the MAC and profile columns are required. Rows without a MAC or profile are skipped.
+func Parse_ise_csv(r io.Reader) ([]IseEndpoint, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading ISE CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		key := strings.ToLower(strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, name))
+		if field, ok := ise_csv_columns[key]; ok {
+			if _, dup := columns[field]; !dup {
+				columns[field] = i
+			}
+		}
+	}
+	for _, field := range []string{"mac", "profile"} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("error: ISE CSV has no %s column", field)
+		}
+	}
+
+	var endpoints []IseEndpoint
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return endpoints, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading ISE CSV line %d: %w", line, err)
+		}
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if endpoint, ok := new_ise_endpoint(value("mac"), value("profile"), value("switch"), value("interface"), value("last_change")); ok {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+}
+
+// new_ise_endpoint normalizes the MAC and interface of an ISE record. ok is false without a valid MAC or a profile.
+func new_ise_endpoint(mac, profile, switch_ip, nas_port, last_change string) (IseEndpoint, bool) {
+	hex := strings.ToLower(mac_hex(mac))
+	profile = strings.TrimSpace(profile)
+	if len(hex) != 12 || profile == "" {
+		return IseEndpoint{}, false
+	}
+	return IseEndpoint{
+		MacAddress:      hex[0:4] + "." + hex[4:8] + "." + hex[8:12],
+		Profile:         profile,
+		SwitchIPAddress: strings.TrimSpace(switch_ip),
+		Interface:       normalize_interface_name(strings.TrimSpace(nas_port)),
+		LastChange:      strings.TrimSpace(last_change),
+	}, true
+}
+
+// ise_profile_kept reports whether profile contains one of profiles, any profile when there are none.
+func ise_profile_kept(profile string, profiles []string) bool {
+	if len(profiles) == 0 {
+		return true
+	}
+	for _, p := range profiles {
+		if strings.Contains(strings.ToLower(profile), strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func first_non_empty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// IseImportResult reports what Import_ise or Import_ise_csv stored.
+type IseImportResult struct {
+	// Endpoints is the number of ise_ip_phones rows written.
+	Endpoints int
+	// Failed is the number of sessions whose ISE request failed; Import_ise_csv leaves it 0.
+	Failed   int
+	Duration time.Duration
+}
+
+// Import_ise replaces ise_ip_phones with the active sessions of the ISE MnT API configured in s.ISE.
+func (s *Store) Import_ise(ctx context.Context) (IseImportResult, error) {
+	start := time.Now()
+	var result IseImportResult
+	if s.ISE == nil {
+		return result, fmt.Errorf("error: no ISE API configured, set ise.url in the config")
+	}
+
+	endpoints, failed, err := s.ISE.Endpoints(ctx)
+	if err != nil {
+		return result, err
+	}
+	result.Failed = len(failed)
+	for mac, err := range failed {
+		log.Printf("Import ISE :: %s :: %v", mac, err)
+	}
+	if len(endpoints) == 0 && len(failed) > 0 {
+		return result, fmt.Errorf("error: every ISE session request failed, ise_ip_phones left unchanged")
+	}
+
+	if result.Endpoints, err = s.store_ise_endpoints(ctx, endpoints); err != nil {
+		return result, err
+	}
+	result.Duration = time.Since(start)
+	log.Printf("Import ISE :: %d endpoints stored, %d failed in %s", result.Endpoints, result.Failed, result.Duration.Round(time.Millisecond))
+	return result, nil
+}
+
+// Import_ise_csv replaces ise_ip_phones with the endpoints of an ISE CSV export, filtered by profiles like Import_ise.
+func (s *Store) Import_ise_csv(ctx context.Context, path string, profiles ...string) (IseImportResult, error) {
+	start := time.Now()
+	var result IseImportResult
+
+	f, err := os.Open(path)
+	if err != nil {
+		return result, fmt.Errorf("error reading ISE CSV: %w", err)
+	}
+	defer f.Close()
+	parsed, err := Parse_ise_csv(f)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var endpoints []IseEndpoint
+	for _, endpoint := range parsed {
+		if ise_profile_kept(endpoint.Profile, profiles) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	if result.Endpoints, err = s.store_ise_endpoints(ctx, endpoints); err != nil {
+		return result, err
+	}
+	result.Duration = time.Since(start)
+	log.Printf("Import ISE :: %d endpoints stored from %s in %s", result.Endpoints, path, result.Duration.Round(time.Millisecond))
+	return result, nil
+}
+
+// store_ise_endpoints replaces the rows of ise_ip_phones in one transaction, one row per MAC (the first one listed).
+func (s *Store) store_ise_endpoints(ctx context.Context, endpoints []IseEndpoint) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning ise_ip_phones transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `ise_ip_phones`"); err != nil {
+		return 0, fmt.Errorf("error clearing ise_ip_phones: %w", err)
+	}
+
+	const batchSize = 1000
+	sqlStr := "INSERT INTO `ise_ip_phones` (`mac_address`, `profile`, `switch_ip_address`, `interface`, `last_change`) VALUES "
+	placeholderRow := "(?, ?, ?, ?, ?)"
+	null_if_empty := func(v string) any {
+		if v == "" {
+			return nil
+		}
+		return v
+	}
+
+	var valueStrings []string
+	var valueArgs []any
+	stored := 0
+	flush := func() error {
+		if len(valueStrings) == 0 {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr+strings.Join(valueStrings, ","), valueArgs...); err != nil {
+			return fmt.Errorf("error inserting ise_ip_phones: %w", err)
+		}
+		valueStrings, valueArgs = valueStrings[:0], valueArgs[:0]
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range endpoints {
+		if seen[e.MacAddress] {
+			continue
+		}
+		seen[e.MacAddress] = true
+		valueStrings = append(valueStrings, placeholderRow)
+		valueArgs = append(valueArgs, e.MacAddress, e.Profile, null_if_empty(e.SwitchIPAddress), null_if_empty(e.Interface), null_if_empty(e.LastChange))
+		stored++
+		if len(valueStrings) == batchSize {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing ise_ip_phones: %w", err)
+	}
+	return stored, nil
+}
+
+// Import_ise runs s.Import_ise against Default_store.
+func Import_ise(ctx context.Context) (IseImportResult, error) {
+	s, err := Default_store()
+	if err != nil {
+		return IseImportResult{}, err
+	}
+	return s.Import_ise(ctx)
+}
+
+// Import_ise_csv runs s.Import_ise_csv against Default_store.
+func Import_ise_csv(ctx context.Context, path string, profiles ...string) (IseImportResult, error) {
+	s, err := Default_store()
+	if err != nil {
+		return IseImportResult{}, err
+	}
+	return s.Import_ise_csv(ctx, path, profiles...)
+}
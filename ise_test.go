@@ -0,0 +1,210 @@
+package cisco_database
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ise_stub serves the MnT API paths read by ISEClient from fixed XML documents.
+// A session missing from sessions is a 404, one listed in broken answers 500.
+type ise_stub struct {
+	active   []string
+	sessions map[string]string
+	broken   map[string]bool
+	// status overrides the ActiveList response when it is not 0.
+	status int
+}
+
+func (s *ise_stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != "api" || password != "secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/admin/API/mnt/Session/ActiveList" {
+		if s.status != 0 {
+			http.Error(w, "ActiveList unavailable", s.status)
+			return
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><activeList noOfActiveSession="%d">`, len(s.active))
+		for _, mac := range s.active {
+			fmt.Fprintf(w, `<activeSession><calling_station_id>%s</calling_station_id><server>ise01</server></activeSession>`, mac)
+		}
+		fmt.Fprint(w, `</activeList>`)
+		return
+	}
+
+	mac, ok := strings.CutPrefix(r.URL.Path, "/admin/API/mnt/Session/MACAddress/")
+	switch {
+	case !ok:
+		http.NotFound(w, r)
+	case s.broken[mac]:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	case s.sessions[mac] == "":
+		http.NotFound(w, r)
+	default:
+		fmt.Fprint(w, s.sessions[mac])
+	}
+}
+
+func ise_session_xml(mac, profile, nas_ip, nas_port string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><sessionParameters>` +
+		`<calling_station_id>` + mac + `</calling_station_id>` +
+		`<nas_ip_address>` + nas_ip + `</nas_ip_address>` +
+		`<nas_port_id>` + nas_port + `</nas_port_id>` +
+		`<endpoint_policy>` + profile + `</endpoint_policy>` +
+		`<auth_acs_timestamp>2026-10-01T08:00:00.000+00:00</auth_acs_timestamp>` +
+		`</sessionParameters>`
+}
+
+func new_ise_stub() *ise_stub {
+	return &ise_stub{
+		active: []string{"00:11:22:33:44:55", "AA:BB:CC:DD:EE:FF", "00:00:5E:00:53:01", "00:00:5E:00:53:02", "00:11:22:33:44:55"},
+		sessions: map[string]string{
+			"00:11:22:33:44:55": ise_session_xml("00:11:22:33:44:55", "Cisco-IP-Phone-8841", "10.0.0.1", "GigabitEthernet1/0/1"),
+			"AA:BB:CC:DD:EE:FF": ise_session_xml("AA:BB:CC:DD:EE:FF", "Windows10-Workstation", "10.0.0.1", "GigabitEthernet1/0/2"),
+		},
+		// 00:00:5E:00:53:01 ended before its session was read and is a 404.
+		broken: map[string]bool{"00:00:5E:00:53:02": true},
+	}
+}
+
+func new_ise_test_client(t *testing.T, handler http.Handler, profiles ...string) *ISEClient {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	client, err := New_ise_client(ISEConfig{URL: server.URL + "/", Username: "api", Password: "secret", InsecureSkipVerify: true, Profiles: profiles})
+	if err != nil {
+		t.Fatalf("New_ise_client: %v", err)
+	}
+	return client
+}
+
+func TestIseActiveSessions(t *testing.T) {
+	client := new_ise_test_client(t, new_ise_stub())
+
+	macs, err := client.Active_sessions(context.Background())
+	if err != nil {
+		t.Fatalf("Active_sessions: %v", err)
+	}
+	want := []string{"00:11:22:33:44:55", "AA:BB:CC:DD:EE:FF", "00:00:5E:00:53:01", "00:00:5E:00:53:02"}
+	if !slices.Equal(macs, want) {
+		t.Errorf("Active_sessions = %v, want %v", macs, want)
+	}
+}
+
+func TestIseSession(t *testing.T) {
+	client := new_ise_test_client(t, new_ise_stub())
+	ctx := context.Background()
+
+	endpoint, ok, err := client.Session(ctx, "00:11:22:33:44:55")
+	want := IseEndpoint{MacAddress: "0011.2233.4455", Profile: "Cisco-IP-Phone-8841", SwitchIPAddress: "10.0.0.1", Interface: "Gi1/0/1", LastChange: "2026-10-01T08:00:00.000+00:00"}
+	if err != nil || !ok || endpoint != want {
+		t.Errorf("Session = %+v, %v, %v, want %+v", endpoint, ok, err, want)
+	}
+
+	if _, ok, err := client.Session(ctx, "00:00:5E:00:53:01"); ok || err != nil {
+		t.Errorf("Session of an ended session = %v, %v, want not found and no error", ok, err)
+	}
+
+	if _, _, err := client.Session(ctx, "00:00:5E:00:53:02"); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Session on a 500 = %v, want the status in the error", err)
+	}
+}
+
+func TestIseEndpoints(t *testing.T) {
+	client := new_ise_test_client(t, new_ise_stub())
+
+	endpoints, failed, err := client.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints: %v", err)
+	}
+	slices.SortFunc(endpoints, func(a, b IseEndpoint) int { return strings.Compare(a.MacAddress, b.MacAddress) })
+	var macs []string
+	for _, endpoint := range endpoints {
+		macs = append(macs, endpoint.MacAddress)
+	}
+	if want := []string{"0011.2233.4455", "aabb.ccdd.eeff"}; !slices.Equal(macs, want) {
+		t.Errorf("Endpoints = %v, want %v", macs, want)
+	}
+	if len(failed) != 1 || failed["00:00:5E:00:53:02"] == nil {
+		t.Errorf("failed = %v, want only 00:00:5E:00:53:02", failed)
+	}
+}
+
+func TestIseEndpointsProfileFilter(t *testing.T) {
+	client := new_ise_test_client(t, new_ise_stub(), "cisco-ip-phone")
+
+	endpoints, _, err := client.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].Profile != "Cisco-IP-Phone-8841" {
+		t.Errorf("Endpoints = %+v, want only the Cisco-IP-Phone endpoint", endpoints)
+	}
+}
+
+func TestIseErrorStatus(t *testing.T) {
+	stub := new_ise_stub()
+	stub.status = http.StatusServiceUnavailable
+	client := new_ise_test_client(t, stub)
+
+	_, _, err := client.Endpoints(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "ActiveList unavailable") {
+		t.Errorf("Endpoints on a 503 = %v, want the status and body in the error", err)
+	}
+
+	server := httptest.NewTLSServer(new_ise_stub())
+	t.Cleanup(server.Close)
+	wrong, err := New_ise_client(ISEConfig{URL: server.URL, Username: "api", Password: "wrong", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("New_ise_client: %v", err)
+	}
+	if _, err := wrong.Active_sessions(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Active_sessions with a wrong password = %v, want a 401 error", err)
+	}
+}
+
+func TestParseIseCsv(t *testing.T) {
+	want := []IseEndpoint{
+		{MacAddress: "0011.2233.4455", Profile: "Cisco-IP-Phone-8841", SwitchIPAddress: "10.0.0.1", Interface: "Gi1/0/1", LastChange: "2026-10-01 08:00:00"},
+		{MacAddress: "aabb.ccdd.eeff", Profile: "Cisco-IP-Phone-7821", SwitchIPAddress: "10.0.0.2", Interface: "Te2/0/48", LastChange: "2026-10-02 09:00:00"},
+	}
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{"endpoint export", "MACAddress,EndPointPolicy,NAS IP Address,NAS Port Id,UpdateTime\n" +
+			"00:11:22:33:44:55,Cisco-IP-Phone-8841,10.0.0.1,GigabitEthernet1/0/1,2026-10-01 08:00:00\n" +
+			"AA-BB-CC-DD-EE-FF,Cisco-IP-Phone-7821,10.0.0.2,TenGigabitEthernet2/0/48,2026-10-02 09:00:00\n"},
+		{"session export", "calling_station_id,endpoint_policy,nas_ip_address,nas_port_id,auth_acs_timestamp\n" +
+			"00:11:22:33:44:55,Cisco-IP-Phone-8841,10.0.0.1,GigabitEthernet1/0/1,2026-10-01 08:00:00\n" +
+			"aabb.ccdd.eeff,Cisco-IP-Phone-7821,10.0.0.2,TenGigabitEthernet2/0/48,2026-10-02 09:00:00\n"},
+		{"report export", "\"Endpoint ID\",\"Endpoint Profile\",\"Device IP Address\",\"Interface\",\"Updated\"\n" +
+			"\"00:11:22:33:44:55\",\"Cisco-IP-Phone-8841\",\"10.0.0.1\",\"GigabitEthernet1/0/1\",\"2026-10-01 08:00:00\"\n" +
+			"\"AABBCCDDEEFF\",\"Cisco-IP-Phone-7821\",\"10.0.0.2\",\"TenGigabitEthernet2/0/48\",\"2026-10-02 09:00:00\"\n" +
+			"\"\",\"Cisco-IP-Phone-7821\",\"10.0.0.2\",\"Gi1/0/3\",\"\"\n" +
+			"\"00:11:22:33:44:66\",\"\",\"10.0.0.2\",\"Gi1/0/4\",\"\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints, err := Parse_ise_csv(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatalf("Parse_ise_csv: %v", err)
+			}
+			if !slices.Equal(endpoints, want) {
+				t.Errorf("Parse_ise_csv =\n%+v\nwant\n%+v", endpoints, want)
+			}
+		})
+	}
+}
+
+func TestParseIseCsvMissingColumn(t *testing.T) {
+	if _, err := Parse_ise_csv(strings.NewReader("MACAddress,NAS IP Address\n00:11:22:33:44:55,10.0.0.1\n")); err == nil {
+		t.Errorf("Parse_ise_csv without a profile column did not fail")
+	}
+}
@@ -0,0 +1,220 @@
+package cisco_database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// The schema lives in migrations/NNNN_name.sql, applied in version order by Migrate.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// tolerated_errors are the MySQL errors of statements whose change is already in place,
+// e.g. a database created by the old schema.sql, or a migration interrupted half way
+// (MySQL DDL is not transactional). Skipping them makes every migration safe to re-run.
+var tolerated_errors = map[uint16]string{
+	1050: "table already exists",
+	1060: "duplicate column",
+	1061: "duplicate index",
+	1022: "duplicate foreign key",
+	1826: "duplicate foreign key",
+	1091: "column or index already dropped",
+}
+
+// migrate_lock serializes Migrate across processes sharing the database.
+const migrate_lock = "cisco_database_migrate"
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, rest, found := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !found || err != nil {
+			return nil, fmt.Errorf("error: migration %s must be named NNNN_name.sql", name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("error: migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: rest, Statements: split_statements(string(data))})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// split_statements splits a migration file on the semicolons ending a line,
+// dropping the "--" comment lines.
+func split_statements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// Schema_version returns the highest migration applied to the database, 0 for a new database.
+func Schema_version(ctx context.Context, db *sql.DB) (int, error) {
+	if err := ensure_schema_version(ctx, db); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(`version`) FROM `schema_version`").Scan(&version); err != nil {
+		return 0, fmt.Errorf("error reading schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Pending_migrations returns the migrations not yet applied to the database.
+func Pending_migrations(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensure_schema_version(ctx, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := Return_query_context(ctx, db, "SELECT `version` FROM `schema_version`")
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema version: %w", err)
+	}
+	applied := make(map[int64]bool)
+	for _, row := range rows {
+		applied[Row_int64(row, "version")] = true
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if !applied[int64(m.Version)] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies every pending migration in version order and returns the ones it applied.
+// It can bring any older install up to date, including databases created by the old schema.sql.
+func Migrate(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	// One connection holds the lock for the whole run.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the database: %w", err)
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", migrate_lock).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("error taking the migration lock: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return nil, fmt.Errorf("error: another process is migrating the database")
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", migrate_lock)
+
+	pending, err := Pending_migrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		log.Printf("Migrate :: %04d_%s :: %d statements", m.Version, m.Name, len(m.Statements))
+		for i, statement := range m.Statements {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				if reason, ok := tolerated(err); ok {
+					log.Printf("Migrate :: %04d_%s :: statement %d skipped, %s", m.Version, m.Name, i+1, reason)
+					continue
+				}
+				return applied, fmt.Errorf("error applying migration %04d_%s statement %d: %w", m.Version, m.Name, i+1, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO `schema_version` (`version`, `name`, `applied_at`) VALUES (?, ?, ?)", m.Version, m.Name, time.Now().UTC()); err != nil {
+			return applied, fmt.Errorf("error recording migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// tolerated reports whether err means the statement's change is already in place.
+func tolerated(err error) (string, bool) {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return "", false
+	}
+	reason, ok := tolerated_errors[mysqlErr.Number]
+	return reason, ok
+}
+
+func ensure_schema_version(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `schema_version` ("+
+		"`version` INT PRIMARY KEY NOT NULL, "+
+		"`name` VARCHAR(255) NOT NULL, "+
+		"`applied_at` DATETIME(3) NOT NULL"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4")
+	if err != nil {
+		return fmt.Errorf("error creating schema_version table: %w", err)
+	}
+	return nil
+}
+
+// Migrate runs Migrate against the Store database.
+func (s *Store) Migrate(ctx context.Context) ([]Migration, error) {
+	return Migrate(ctx, s.DB)
+}
+
+// Pending_migrations runs Pending_migrations against the Store database.
+func (s *Store) Pending_migrations(ctx context.Context) ([]Migration, error) {
+	return Pending_migrations(ctx, s.DB)
+}
@@ -1,3 +1,6 @@
+-- Original schema. Installs created from the old schema.sql already have it;
+-- the statements that would fail on them are tolerated by Migrate.
+
 CREATE TABLE IF NOT EXISTS `switches` (
   `id` INT PRIMARY KEY AUTO_INCREMENT NOT NULL,
   `fqdn` TEXT NULL,
@@ -248,4 +251,4 @@ LEFT JOIN (
 LEFT JOIN vendors ON SUBSTRING(REPLACE(interfaces.mac_address, '.', ''), 1, 6) = vendors.mac_address
 JOIN akips_interface_usage ON akips_interface_usage.switch_id = interfaces.switch_id AND akips_interface_usage.interface = interfaces.interface
 WHERE
-	DATE(interfaces.created_at) = CURDATE() ORDER BY interfaces.id
+	DATE(interfaces.created_at) = CURDATE() ORDER BY interfaces.id;
@@ -0,0 +1,2 @@
+-- Role used by Registry.Disable_for_role.
+ALTER TABLE `switches` ADD COLUMN `role` VARCHAR(64) NULL AFTER `location`;
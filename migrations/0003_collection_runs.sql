@@ -0,0 +1,96 @@
+-- Collection runs: every collected row carries the run it belongs to.
+CREATE TABLE IF NOT EXISTS `collection_runs` (
+  `id` INT PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `started_at` DATETIME(3) NOT NULL,
+  `finished_at` DATETIME(3) NULL,
+  `trigger` VARCHAR(64) NOT NULL,
+  `status` VARCHAR(16) NOT NULL DEFAULT 'running'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE `interfaces` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `interfaces` ADD CONSTRAINT `fk_interfaces_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `interfaces` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `interfaces_status` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `interfaces_status` ADD CONSTRAINT `fk_interfaces_status_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `interfaces_status` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `power_modules` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `power_modules` ADD CONSTRAINT `fk_power_modules_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `power_modules` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `power_interfaces` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `power_interfaces` ADD CONSTRAINT `fk_power_interfaces_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `power_interfaces` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `vlans` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `vlans` ADD CONSTRAINT `fk_vlans_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `vlans` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `cdp_neighbors` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `cdp_neighbors` ADD CONSTRAINT `fk_cdp_neighbors_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `cdp_neighbors` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `lldp_neighbors` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `lldp_neighbors` ADD CONSTRAINT `fk_lldp_neighbors_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `lldp_neighbors` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `mac_address_table` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `mac_address_table` ADD CONSTRAINT `fk_mac_address_table_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `mac_address_table` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `show_running_config` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `show_running_config` ADD CONSTRAINT `fk_show_running_config_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `show_running_config` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+ALTER TABLE `akips_interface_usage` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `akips_interface_usage` ADD CONSTRAINT `fk_akips_interface_usage_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `akips_interface_usage` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+CREATE OR REPLACE VIEW `view_interfaces` AS
+SELECT
+  switches.id as switch_id,
+  switches.ip_address as switch_ip_address,
+	switches.fqdn,
+	interfaces.id as interface_id,
+	interfaces.run_id,
+	interfaces.interface,
+	interfaces.mac_address,
+	interfaces.ip_address,
+	vendors.vendor,
+	interfaces.description,
+	interfaces.link_status as status,
+	CASE
+	  WHEN ise_check.interface IS NOT NULL THEN 'ISE' ELSE NULL
+	END AS ise,
+	interfaces.vlan_id,
+	interfaces.vlan_name,
+	akips_interface_usage.last_change,
+	interfaces.created_at
+FROM interfaces
+JOIN (
+    SELECT
+      switch_id,
+      MAX(run_id) AS run_id
+    FROM
+      interfaces
+    GROUP BY
+      switch_id
+  ) AS latest ON latest.switch_id = interfaces.switch_id
+  AND latest.run_id = interfaces.run_id
+JOIN switches ON switches.id = interfaces.switch_id
+LEFT JOIN (
+    SELECT
+      DISTINCT switch_id,
+      run_id,
+      interface
+    FROM
+      show_running_config
+    WHERE
+      `configuration` LIKE '%authentication priority dot1x mab%'
+  ) AS ise_check ON interfaces.switch_id = ise_check.switch_id
+  AND interfaces.run_id = ise_check.run_id
+  AND interfaces.interface = ise_check.interface
+LEFT JOIN vendors ON SUBSTRING(REPLACE(interfaces.mac_address, '.', ''), 1, 6) = vendors.mac_address
+JOIN akips_interface_usage ON akips_interface_usage.switch_id = interfaces.switch_id AND akips_interface_usage.run_id = interfaces.run_id AND akips_interface_usage.interface = interfaces.interface
+ORDER BY interfaces.id;
@@ -0,0 +1,42 @@
+-- Typed copies of the interfaces counters. The TEXT columns keep the raw
+-- value parsed from "show interfaces" for debugging.
+ALTER TABLE `interfaces` ADD COLUMN `mtu_bytes` INT NULL AFTER `collisions`;
+ALTER TABLE `interfaces` ADD COLUMN `bandwidth_kbit` BIGINT NULL AFTER `mtu_bytes`;
+ALTER TABLE `interfaces` ADD COLUMN `delay_usec` BIGINT NULL AFTER `bandwidth_kbit`;
+ALTER TABLE `interfaces` ADD COLUMN `reliability_fraction` DOUBLE NULL AFTER `delay_usec`;
+ALTER TABLE `interfaces` ADD COLUMN `txload_fraction` DOUBLE NULL AFTER `reliability_fraction`;
+ALTER TABLE `interfaces` ADD COLUMN `rxload_fraction` DOUBLE NULL AFTER `txload_fraction`;
+ALTER TABLE `interfaces` ADD COLUMN `input_rate_bps` BIGINT NULL AFTER `rxload_fraction`;
+ALTER TABLE `interfaces` ADD COLUMN `output_rate_bps` BIGINT NULL AFTER `input_rate_bps`;
+ALTER TABLE `interfaces` ADD COLUMN `input_packets_total` BIGINT NULL AFTER `output_rate_bps`;
+ALTER TABLE `interfaces` ADD COLUMN `output_packets_total` BIGINT NULL AFTER `input_packets_total`;
+ALTER TABLE `interfaces` ADD COLUMN `input_bytes_total` BIGINT NULL AFTER `output_packets_total`;
+ALTER TABLE `interfaces` ADD COLUMN `output_bytes_total` BIGINT NULL AFTER `input_bytes_total`;
+ALTER TABLE `interfaces` ADD COLUMN `runts_total` BIGINT NULL AFTER `output_bytes_total`;
+ALTER TABLE `interfaces` ADD COLUMN `giants_total` BIGINT NULL AFTER `runts_total`;
+ALTER TABLE `interfaces` ADD COLUMN `throttles_total` BIGINT NULL AFTER `giants_total`;
+ALTER TABLE `interfaces` ADD COLUMN `input_errors_total` BIGINT NULL AFTER `throttles_total`;
+ALTER TABLE `interfaces` ADD COLUMN `output_errors_total` BIGINT NULL AFTER `input_errors_total`;
+ALTER TABLE `interfaces` ADD COLUMN `crc_errors_total` BIGINT NULL AFTER `output_errors_total`;
+ALTER TABLE `interfaces` ADD COLUMN `collisions_total` BIGINT NULL AFTER `crc_errors_total`;
+
+-- Backfill the rows collected before this migration.
+UPDATE `interfaces` SET
+  `mtu_bytes` = IF(`mtu` REGEXP '^[0-9]+$', CAST(`mtu` AS UNSIGNED), NULL),
+  `bandwidth_kbit` = IF(`bandwidth` REGEXP '^[0-9]+$', CAST(`bandwidth` AS UNSIGNED), NULL),
+  `delay_usec` = IF(`delay` REGEXP '^[0-9]+$', CAST(`delay` AS UNSIGNED), NULL),
+  `reliability_fraction` = IF(`reliability` REGEXP '^[0-9]+/[1-9][0-9]*$', SUBSTRING_INDEX(`reliability`, '/', 1) / SUBSTRING_INDEX(`reliability`, '/', -1), NULL),
+  `txload_fraction` = IF(`txload` REGEXP '^[0-9]+/[1-9][0-9]*$', SUBSTRING_INDEX(`txload`, '/', 1) / SUBSTRING_INDEX(`txload`, '/', -1), NULL),
+  `rxload_fraction` = IF(`rxload` REGEXP '^[0-9]+/[1-9][0-9]*$', SUBSTRING_INDEX(`rxload`, '/', 1) / SUBSTRING_INDEX(`rxload`, '/', -1), NULL),
+  `input_rate_bps` = IF(`input_rate` REGEXP '^[0-9]+$', CAST(`input_rate` AS UNSIGNED), NULL),
+  `output_rate_bps` = IF(`output_rate` REGEXP '^[0-9]+$', CAST(`output_rate` AS UNSIGNED), NULL),
+  `input_packets_total` = IF(`input_packets` REGEXP '^[0-9]+$', CAST(`input_packets` AS UNSIGNED), NULL),
+  `output_packets_total` = IF(`output_packets` REGEXP '^[0-9]+$', CAST(`output_packets` AS UNSIGNED), NULL),
+  `runts_total` = IF(`runts` REGEXP '^[0-9]+$', CAST(`runts` AS UNSIGNED), NULL),
+  `giants_total` = IF(`giants` REGEXP '^[0-9]+$', CAST(`giants` AS UNSIGNED), NULL),
+  `throttles_total` = IF(`throttles` REGEXP '^[0-9]+$', CAST(`throttles` AS UNSIGNED), NULL),
+  `input_errors_total` = IF(`input_errors` REGEXP '^[0-9]+$', CAST(`input_errors` AS UNSIGNED), NULL),
+  `output_errors_total` = IF(`output_errors` REGEXP '^[0-9]+$', CAST(`output_errors` AS UNSIGNED), NULL),
+  `crc_errors_total` = IF(`crc_errors` REGEXP '^[0-9]+$', CAST(`crc_errors` AS UNSIGNED), NULL),
+  `collisions_total` = IF(`collisions` REGEXP '^[0-9]+$', CAST(`collisions` AS UNSIGNED), NULL)
+WHERE `mtu_bytes` IS NULL;
@@ -0,0 +1,2 @@
+-- Prune looks up the runs older than the retention period.
+ALTER TABLE `collection_runs` ADD INDEX `idx_started` (started_at);
@@ -0,0 +1,29 @@
+-- Change events found by Detect_changes between consecutive runs of a switch.
+-- run_id has no foreign key: changes outlive the snapshots removed by Prune.
+CREATE TABLE IF NOT EXISTS `changes` (
+  `id` BIGINT PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `switch_id` INT NOT NULL,
+  `run_id` INT NOT NULL,
+  `previous_run_id` INT NOT NULL,
+  `entity` VARCHAR(64) NOT NULL,
+  `entity_key` VARCHAR(255) NOT NULL,
+  `interface` VARCHAR(128) NULL,
+  `change_type` VARCHAR(16) NOT NULL,
+  `field` VARCHAR(64) NULL,
+  `old_value` TEXT NULL,
+  `new_value` TEXT NULL,
+  `detected_at` DATETIME(3) NOT NULL,
+  INDEX `idx_sw_date` (switch_id, detected_at),
+  INDEX `idx_sw_if_date` (switch_id, interface, detected_at),
+  INDEX `idx_date` (detected_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- The runs Detect_changes already compared, so it can be re-run safely.
+CREATE TABLE IF NOT EXISTS `changes_checked` (
+  `switch_id` INT NOT NULL,
+  `entity` VARCHAR(64) NOT NULL,
+  `run_id` INT NOT NULL,
+  `previous_run_id` INT NOT NULL,
+  `checked_at` DATETIME(3) NOT NULL,
+  PRIMARY KEY (switch_id, entity, run_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,26 @@
+-- Full running-configs archived by Show_running_config, one row per distinct config of a switch.
+CREATE TABLE IF NOT EXISTS `config_versions` (
+  `id` INT PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `switch_id` INT NOT NULL,
+  `sha256` CHAR(64) NOT NULL,
+  `config` MEDIUMTEXT NOT NULL,
+  `lines` INT NOT NULL,
+  `first_run_id` INT NULL,
+  `last_run_id` INT NULL,
+  `first_seen_at` DATETIME(3) NOT NULL,
+  `last_seen_at` DATETIME(3) NOT NULL,
+  UNIQUE KEY `uq_sw_sha256` (switch_id, sha256),
+  INDEX `idx_sw_seen` (switch_id, last_seen_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- The config version collected by each run.
+-- run_id has no foreign key: the archive outlives the runs removed by Prune.
+CREATE TABLE IF NOT EXISTS `config_snapshots` (
+  `switch_id` INT NOT NULL,
+  `run_id` INT NOT NULL,
+  `config_version_id` INT NOT NULL,
+  `collected_at` DATETIME(3) NOT NULL,
+  PRIMARY KEY (switch_id, run_id),
+  INDEX `idx_version` (config_version_id),
+  CONSTRAINT `fk_config_snapshots_version` FOREIGN KEY (config_version_id) REFERENCES config_versions(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
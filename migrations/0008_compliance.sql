@@ -0,0 +1,15 @@
+-- Latest compliance results of each switch, written by Check_compliance.
+CREATE TABLE IF NOT EXISTS `compliance_results` (
+  `id` BIGINT PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `switch_id` INT NOT NULL,
+  `config_version_id` INT NOT NULL,
+  `rule_id` VARCHAR(128) NOT NULL,
+  `severity` VARCHAR(16) NOT NULL,
+  `section` TEXT NOT NULL,
+  `interface` VARCHAR(128) NULL,
+  `passed` TINYINT(1) NOT NULL,
+  `detail` TEXT NOT NULL,
+  `checked_at` DATETIME(3) NOT NULL,
+  INDEX `idx_sw_rule` (switch_id, rule_id),
+  INDEX `idx_rule_passed` (rule_id, passed)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,18 @@
+-- Reachability recorded by Process_switch from an SSH probe of each switch.
+ALTER TABLE `switches` ADD COLUMN `reachability` VARCHAR(16) NULL AFTER `reachable`;
+ALTER TABLE `switches` ADD COLUMN `last_checked_at` DATETIME(3) NULL AFTER `reachability`;
+ALTER TABLE `switches` ADD COLUMN `last_seen_at` DATETIME(3) NULL AFTER `last_checked_at`;
+ALTER TABLE `switches` ADD COLUMN `last_error` TEXT NULL AFTER `last_seen_at`;
+
+-- Every probe, to find flapping switches. Pruned by age, see RetentionPolicy.
+CREATE TABLE IF NOT EXISTS `reachability_history` (
+  `id` BIGINT PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `switch_id` INT NOT NULL,
+  `run_id` INT NULL,
+  `state` VARCHAR(16) NOT NULL,
+  `error` TEXT NULL,
+  `latency_ms` INT NULL,
+  `checked_at` DATETIME(3) NOT NULL,
+  INDEX `idx_sw_date` (switch_id, checked_at),
+  INDEX `idx_date` (checked_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
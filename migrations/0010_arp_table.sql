@@ -0,0 +1,9 @@
+-- ARP entries collected by Show_ip_arp from the gateway switches, per run and VRF.
+ALTER TABLE `arp_table` ADD COLUMN `switch_id` INT NULL AFTER `id`;
+ALTER TABLE `arp_table` ADD COLUMN `run_id` INT NULL AFTER `switch_id`;
+ALTER TABLE `arp_table` ADD COLUMN `vrf` VARCHAR(64) NOT NULL DEFAULT 'default' AFTER `source`;
+ALTER TABLE `arp_table` ADD CONSTRAINT `fk_arp_table_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`);
+ALTER TABLE `arp_table` ADD INDEX `idx_sw_run` (switch_id, run_id);
+
+-- Rows imported before the collector existed keep their source device.
+UPDATE `arp_table` a JOIN `switches` s ON s.`fqdn` = a.`source` SET a.`switch_id` = s.`id` WHERE a.`switch_id` IS NULL;
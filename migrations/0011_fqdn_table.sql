@@ -0,0 +1,6 @@
+-- fqdn_table holds the PTR name of each ARP entry, upserted by Update_fqdn_table.
+DELETE f1 FROM `fqdn_table` f1 JOIN `fqdn_table` f2
+  ON f1.`mac_address` = f2.`mac_address` AND f1.`ip_address` = f2.`ip_address` AND f1.`id` < f2.`id`;
+ALTER TABLE `fqdn_table` MODIFY `mac_address` VARCHAR(32) NOT NULL, MODIFY `ip_address` VARCHAR(45) NOT NULL;
+ALTER TABLE `fqdn_table` ADD COLUMN `resolved_at` DATETIME(3) NULL AFTER `fqdn`;
+ALTER TABLE `fqdn_table` ADD UNIQUE KEY `uq_mac_ip` (mac_address, ip_address);
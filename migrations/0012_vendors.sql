@@ -0,0 +1,58 @@
+-- vendors holds the IEEE assignments loaded by Import_oui: MA-L prefixes of 6 hex digits,
+-- MA-M of 7 and MA-S of 9. view_interfaces takes the vendor of the longest matching prefix.
+DELETE v1 FROM `vendors` v1 JOIN `vendors` v2 ON v1.`mac_address` = v2.`mac_address` AND v1.`id` < v2.`id`;
+DELETE FROM `vendors` WHERE CHAR_LENGTH(`mac_address`) > 9;
+ALTER TABLE `vendors` MODIFY `mac_address` VARCHAR(9) NOT NULL;
+ALTER TABLE `vendors` ADD COLUMN `registry` VARCHAR(8) NULL AFTER `mac_address`;
+ALTER TABLE `vendors` ADD UNIQUE KEY `uq_mac` (mac_address);
+
+CREATE OR REPLACE VIEW `view_interfaces` AS
+SELECT
+  switches.id as switch_id,
+  switches.ip_address as switch_ip_address,
+	switches.fqdn,
+	interfaces.id as interface_id,
+	interfaces.run_id,
+	interfaces.interface,
+	interfaces.mac_address,
+	interfaces.ip_address,
+	(
+	  SELECT vendors.vendor FROM vendors
+	  WHERE vendors.mac_address IN (SUBSTRING(REPLACE(interfaces.mac_address, '.', ''), 1, 9), SUBSTRING(REPLACE(interfaces.mac_address, '.', ''), 1, 7), SUBSTRING(REPLACE(interfaces.mac_address, '.', ''), 1, 6))
+	  ORDER BY CHAR_LENGTH(vendors.mac_address) DESC LIMIT 1
+	) AS vendor,
+	interfaces.description,
+	interfaces.link_status as status,
+	CASE
+	  WHEN ise_check.interface IS NOT NULL THEN 'ISE' ELSE NULL
+	END AS ise,
+	interfaces.vlan_id,
+	interfaces.vlan_name,
+	akips_interface_usage.last_change,
+	interfaces.created_at
+FROM interfaces
+JOIN (
+    SELECT
+      switch_id,
+      MAX(run_id) AS run_id
+    FROM
+      interfaces
+    GROUP BY
+      switch_id
+  ) AS latest ON latest.switch_id = interfaces.switch_id
+  AND latest.run_id = interfaces.run_id
+JOIN switches ON switches.id = interfaces.switch_id
+LEFT JOIN (
+    SELECT
+      DISTINCT switch_id,
+      run_id,
+      interface
+    FROM
+      show_running_config
+    WHERE
+      `configuration` LIKE '%authentication priority dot1x mab%'
+  ) AS ise_check ON interfaces.switch_id = ise_check.switch_id
+  AND interfaces.run_id = ise_check.run_id
+  AND interfaces.interface = ise_check.interface
+JOIN akips_interface_usage ON akips_interface_usage.switch_id = interfaces.switch_id AND akips_interface_usage.run_id = interfaces.run_id AND akips_interface_usage.interface = interfaces.interface
+ORDER BY interfaces.id;
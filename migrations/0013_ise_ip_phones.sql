@@ -0,0 +1,73 @@
+-- ise_ip_phones holds the ISE endpoints loaded by Import_ise and Import_ise_csv: one row per MAC
+-- with its endpoint profile and the switch port (NAS IP address and NAS port) it authenticated on.
+DELETE p1 FROM `ise_ip_phones` p1 JOIN `ise_ip_phones` p2 ON p1.`mac_address` = p2.`mac_address` AND p1.`id` < p2.`id`;
+ALTER TABLE `ise_ip_phones` MODIFY `mac_address` VARCHAR(32) NOT NULL, MODIFY `profile` VARCHAR(255) NOT NULL,
+  MODIFY `switch_ip_address` VARCHAR(45) NULL, MODIFY `interface` VARCHAR(64) NULL;
+ALTER TABLE `ise_ip_phones` ADD UNIQUE KEY `uq_mac` (mac_address);
+ALTER TABLE `ise_ip_phones` ADD INDEX `idx_switch_if` (switch_ip_address, interface);
+
+CREATE OR REPLACE VIEW `view_interfaces` AS
+SELECT
+  switches.id as switch_id,
+  switches.ip_address as switch_ip_address,
+	switches.fqdn,
+	interfaces.id as interface_id,
+	interfaces.run_id,
+	interfaces.interface,
+	interfaces.mac_address,
+	interfaces.ip_address,
+	(
+	  SELECT vendors.vendor FROM vendors
+	  WHERE vendors.mac_address IN (SUBSTRING(REPLACE(interfaces.mac_address, '.', ''), 1, 9), SUBSTRING(REPLACE(interfaces.mac_address, '.', ''), 1, 7), SUBSTRING(REPLACE(interfaces.mac_address, '.', ''), 1, 6))
+	  ORDER BY CHAR_LENGTH(vendors.mac_address) DESC LIMIT 1
+	) AS vendor,
+	interfaces.description,
+	interfaces.link_status as status,
+	CASE
+	  WHEN ise_check.interface IS NOT NULL THEN 'ISE' ELSE NULL
+	END AS ise,
+	ise_endpoints.profile AS ise_profile,
+	interfaces.vlan_id,
+	interfaces.vlan_name,
+	akips_interface_usage.last_change,
+	interfaces.created_at
+FROM interfaces
+JOIN (
+    SELECT
+      switch_id,
+      MAX(run_id) AS run_id
+    FROM
+      interfaces
+    GROUP BY
+      switch_id
+  ) AS latest ON latest.switch_id = interfaces.switch_id
+  AND latest.run_id = interfaces.run_id
+JOIN switches ON switches.id = interfaces.switch_id
+LEFT JOIN (
+    SELECT
+      DISTINCT switch_id,
+      run_id,
+      interface
+    FROM
+      show_running_config
+    WHERE
+      `configuration` LIKE '%authentication priority dot1x mab%'
+  ) AS ise_check ON interfaces.switch_id = ise_check.switch_id
+  AND interfaces.run_id = ise_check.run_id
+  AND interfaces.interface = ise_check.interface
+LEFT JOIN (
+    SELECT
+      switch_ip_address,
+      interface,
+      GROUP_CONCAT(DISTINCT profile ORDER BY profile SEPARATOR ', ') AS profile
+    FROM
+      ise_ip_phones
+    WHERE
+      switch_ip_address IS NOT NULL AND interface IS NOT NULL
+    GROUP BY
+      switch_ip_address,
+      interface
+  ) AS ise_endpoints ON ise_endpoints.switch_ip_address = switches.ip_address
+  AND ise_endpoints.interface = interfaces.interface
+JOIN akips_interface_usage ON akips_interface_usage.switch_id = interfaces.switch_id AND akips_interface_usage.run_id = interfaces.run_id AND akips_interface_usage.interface = interfaces.interface
+ORDER BY interfaces.id;
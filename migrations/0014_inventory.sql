@@ -0,0 +1,17 @@
+-- inventory holds every entry of "show inventory" (chassis, stack members, line cards, power supplies,
+-- transceivers) collected by Show_inventory, per run.
+CREATE TABLE IF NOT EXISTS `inventory` (
+  `id` BIGINT PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `switch_id` INT NOT NULL,
+  `run_id` INT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `description` VARCHAR(255) NULL,
+  `pid` VARCHAR(64) NULL,
+  `vid` VARCHAR(16) NULL,
+  `serial` VARCHAR(64) NULL,
+  `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+  INDEX `idx_sw_run` (switch_id, run_id),
+  INDEX `idx_serial` (serial),
+  INDEX `idx_pid` (pid),
+  CONSTRAINT `fk_inventory_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,15 @@
+-- collection_steps records the outcome of every step Run_collectors ran for a switch, so a step that
+-- succeeded without storing rows (the last neighbor or PoE port gone) is told apart from one that failed.
+CREATE TABLE IF NOT EXISTS `collection_steps` (
+  `run_id` INT NOT NULL,
+  `switch_id` INT NOT NULL,
+  `step` VARCHAR(64) NOT NULL,
+  `status` VARCHAR(16) NOT NULL,
+  `rows` INT NOT NULL DEFAULT 0,
+  `error_class` VARCHAR(16) NULL,
+  `error` TEXT NULL,
+  `duration_ms` INT NOT NULL DEFAULT 0,
+  PRIMARY KEY (run_id, switch_id, step),
+  INDEX `idx_sw_step` (switch_id, step, run_id),
+  CONSTRAINT `fk_collection_steps_run` FOREIGN KEY (`run_id`) REFERENCES `collection_runs` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,240 @@
+package cisco_database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OUI registries of the IEEE and the length in hex digits of their prefixes.
+var oui_prefix_lengths = map[string]int{
+	"MA-L": 6,
+	"MA-M": 7,
+	"MA-S": 9,
+}
+
+// OuiEntry is one assignment of an IEEE registry file (oui.csv, mam.csv or oui36.csv).
+type OuiEntry struct {
+	// Registry is MA-L, MA-M or MA-S.
+	Registry string
+	// Prefix is the assigned MAC prefix in upper-case hex digits, 6, 7 or 9 of them.
+	Prefix string
+	Vendor string
+}
+
+// Parse_oui_csv reads an IEEE registry CSV ("Registry,Assignment,Organization Name,Organization Address").
+// Rows of other registries (e.g. CID) are skipped, a prefix of the wrong length for its registry is an error.
+func Parse_oui_csv(r io.Reader) ([]OuiEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var entries []OuiEntry
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading OUI file line %d: %w", line, err)
+		}
+		if len(record) < 3 || strings.EqualFold(strings.TrimSpace(record[0]), "Registry") {
+			continue
+		}
+
+		registry := strings.ToUpper(strings.TrimSpace(record[0]))
+		length, ok := oui_prefix_lengths[registry]
+		if !ok {
+			continue
+		}
+		prefix := strings.ToUpper(strings.TrimSpace(record[1]))
+		if len(prefix) != length || strings.Trim(prefix, "0123456789ABCDEF") != "" {
+			return nil, fmt.Errorf("error: OUI file line %d has an invalid %s assignment %q", line, registry, record[1])
+		}
+		entries = append(entries, OuiEntry{Registry: registry, Prefix: prefix, Vendor: strings.TrimSpace(record[2])})
+	}
+}
+
+// Load_oui_files parses the given IEEE CSV files; a directory stands for the *.csv files it holds.
+func Load_oui_files(paths ...string) ([]OuiEntry, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading OUI files: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.csv"))
+		if err != nil {
+			return nil, fmt.Errorf("error reading OUI files: %w", err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("error: no OUI files found in %s", strings.Join(paths, ", "))
+	}
+
+	var entries []OuiEntry
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading OUI files: %w", err)
+		}
+		parsed, err := Parse_oui_csv(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		entries = append(entries, parsed...)
+	}
+	return entries, nil
+}
+
+// OuiImportResult reports what Import_oui loaded.
+type OuiImportResult struct {
+	// Registries is the number of prefixes stored per registry.
+	Registries map[string]int
+	// Duplicates is the number of entries dropped because their prefix was already listed.
+	Duplicates int
+	Duration   time.Duration
+}
+
+// Import_oui replaces the vendors table with the assignments of the IEEE CSV files at paths.
+// The rows are loaded into a copy of the table swapped in with a single RENAME TABLE,
+// so readers see either the old or the new vendors, never a partial table.
+func (s *Store) Import_oui(ctx context.Context, paths ...string) (OuiImportResult, error) {
+	start := time.Now()
+	result := OuiImportResult{Registries: make(map[string]int)}
+
+	entries, err := Load_oui_files(paths...)
+	if err != nil {
+		return result, err
+	}
+
+	// IEEE lists a few prefixes twice, the first one wins.
+	seen := make(map[string]bool)
+	unique := entries[:0]
+	for _, entry := range entries {
+		if seen[entry.Prefix] {
+			result.Duplicates++
+			continue
+		}
+		seen[entry.Prefix] = true
+		unique = append(unique, entry)
+	}
+
+	// vendors_old is left behind by an import whose final DROP failed, and would make the RENAME fail.
+	for _, query := range []string{"DROP TABLE IF EXISTS `vendors_new`", "DROP TABLE IF EXISTS `vendors_old`", "CREATE TABLE `vendors_new` LIKE `vendors`"} {
+		if _, err := s.DB.ExecContext(ctx, query); err != nil {
+			s.drop_vendors_new(ctx)
+			return result, fmt.Errorf("error preparing vendors_new: %w", err)
+		}
+	}
+
+	const batchSize = 1000
+	sqlStr := "INSERT INTO `vendors_new` (`mac_address`, `registry`, `vendor`) VALUES "
+	placeholderRow := "(?, ?, ?)"
+	for i := 0; i < len(unique); i += batchSize {
+		batch := unique[i:min(i+batchSize, len(unique))]
+
+		var valueStrings []string
+		var valueArgs []any
+		for _, entry := range batch {
+			valueStrings = append(valueStrings, placeholderRow)
+			valueArgs = append(valueArgs, entry.Prefix, entry.Registry, entry.Vendor)
+			result.Registries[entry.Registry]++
+		}
+		if _, err := s.DB.ExecContext(ctx, sqlStr+strings.Join(valueStrings, ","), valueArgs...); err != nil {
+			s.drop_vendors_new(ctx)
+			return result, fmt.Errorf("error loading vendors_new: %w", err)
+		}
+	}
+
+	if _, err := s.DB.ExecContext(ctx, "RENAME TABLE `vendors` TO `vendors_old`, `vendors_new` TO `vendors`"); err != nil {
+		s.drop_vendors_new(ctx)
+		return result, fmt.Errorf("error swapping vendors: %w", err)
+	}
+	if _, err := s.DB.ExecContext(ctx, "DROP TABLE `vendors_old`"); err != nil {
+		log.Printf("Import OUI :: error dropping vendors_old: %v", err)
+	}
+
+	result.Duration = time.Since(start)
+	log.Printf("Import OUI :: %d MA-L, %d MA-M and %d MA-S prefixes loaded in %s", result.Registries["MA-L"], result.Registries["MA-M"], result.Registries["MA-S"], result.Duration.Round(time.Millisecond))
+	return result, nil
+}
+
+// drop_vendors_new removes the copy of a failed import, even when ctx was cancelled.
+func (s *Store) drop_vendors_new(ctx context.Context) {
+	if _, err := s.DB.ExecContext(context.WithoutCancel(ctx), "DROP TABLE IF EXISTS `vendors_new`"); err != nil {
+		log.Printf("Import OUI :: error dropping vendors_new: %v", err)
+	}
+}
+
+// Vendor_by_mac returns the organization of the longest IEEE prefix matching mac, in any notation
+// (0050.56a3.1234, 00:50:56:A3:12:34, ...). It returns "" when no prefix matches.
+func (s *Store) Vendor_by_mac(ctx context.Context, mac string) (string, error) {
+	prefixes := oui_prefixes(mac)
+	if prefixes == nil {
+		return "", fmt.Errorf("error: invalid MAC address %q", mac)
+	}
+
+	var vendor sql.NullString
+	err := s.DB.QueryRowContext(ctx, "SELECT `vendor` FROM `vendors` WHERE `mac_address` IN (?, ?, ?) ORDER BY CHAR_LENGTH(`mac_address`) DESC LIMIT 1",
+		prefixes[0], prefixes[1], prefixes[2]).Scan(&vendor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading vendors: %w", err)
+	}
+	return vendor.String, nil
+}
+
+// oui_prefixes returns the MA-S, MA-M and MA-L prefixes of mac, longest first, as stored in vendors.
+// A MAC shorter than the MA-S prefix repeats its longest prefix; nil means it has less than 6 hex digits.
+func oui_prefixes(mac string) []string {
+	hex := mac_hex(mac)
+	if len(hex) < 6 {
+		return nil
+	}
+	return []string{hex[:min(9, len(hex))], hex[:min(7, len(hex))], hex[:6]}
+}
+
+// mac_hex returns the hex digits of a MAC address in any notation, upper-cased.
+func mac_hex(mac string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return r
+		}
+		return -1
+	}, mac))
+}
+
+// Import_oui runs s.Import_oui against Default_store.
+func Import_oui(ctx context.Context, paths ...string) (OuiImportResult, error) {
+	s, err := Default_store()
+	if err != nil {
+		return OuiImportResult{}, err
+	}
+	return s.Import_oui(ctx, paths...)
+}
+
+// Vendor_by_mac runs s.Vendor_by_mac against Default_store.
+func Vendor_by_mac(ctx context.Context, mac string) (string, error) {
+	s, err := Default_store()
+	if err != nil {
+		return "", err
+	}
+	return s.Vendor_by_mac(ctx, mac)
+}
@@ -0,0 +1,65 @@
+package cisco_database
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const oui_csv_fixture = `Registry,Assignment,Organization Name,Organization Address
+MA-L,005056,"VMware, Inc.",3401 Hillview Avenue PALO ALTO CA US 94304
+MA-L,70b3d5,IEEE Registration Authority,445 Hoes Lane Piscataway NJ US 08554
+MA-M,70B3D51,"Example Devices, Ltd",1 Example Road Springfield US
+MA-S,70B3D5123,Tiny Sensors GmbH,Beispielweg 1 Berlin DE
+CID,0A1B2C,Company ID only,not a MAC prefix
+MA-L,001122,
+`
+
+func TestParseOuiCsv(t *testing.T) {
+	entries, err := Parse_oui_csv(strings.NewReader(oui_csv_fixture))
+	if err != nil {
+		t.Fatalf("Parse_oui_csv: %v", err)
+	}
+	want := []OuiEntry{
+		{Registry: "MA-L", Prefix: "005056", Vendor: "VMware, Inc."},
+		{Registry: "MA-L", Prefix: "70B3D5", Vendor: "IEEE Registration Authority"},
+		{Registry: "MA-M", Prefix: "70B3D51", Vendor: "Example Devices, Ltd"},
+		{Registry: "MA-S", Prefix: "70B3D5123", Vendor: "Tiny Sensors GmbH"},
+		{Registry: "MA-L", Prefix: "001122", Vendor: ""},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("Parse_oui_csv =\n%+v\nwant\n%+v", entries, want)
+	}
+}
+
+func TestParseOuiCsvInvalidPrefix(t *testing.T) {
+	tests := []string{
+		"MA-L,00505,Too short\n",
+		"MA-M,70B3D5,MA-L length in MA-M\n",
+		"MA-S,70B3D51234,Too long\n",
+		"MA-L,00505G,Not hex\n",
+	}
+	for _, csv := range tests {
+		if _, err := Parse_oui_csv(strings.NewReader(csv)); err == nil {
+			t.Errorf("Parse_oui_csv(%q) did not fail", csv)
+		}
+	}
+}
+
+func TestOuiPrefixes(t *testing.T) {
+	tests := []struct {
+		mac  string
+		want []string
+	}{
+		{"70b3.d512.3456", []string{"70B3D5123", "70B3D51", "70B3D5"}},
+		{"70:B3:D5:12:34:56", []string{"70B3D5123", "70B3D51", "70B3D5"}},
+		{"00-50-56-a3-12-34", []string{"005056A31", "005056A", "005056"}},
+		{"0050.56", []string{"005056", "005056", "005056"}},
+		{"00:50", nil},
+	}
+	for _, tt := range tests {
+		if got := oui_prefixes(tt.mac); !slices.Equal(got, tt.want) {
+			t.Errorf("oui_prefixes(%q) = %q, want %q", tt.mac, got, tt.want)
+		}
+	}
+}
@@ -1,19 +1,57 @@
 package cisco_database
 
 import (
+	"context"
+	"fmt"
 	"log"
-	"strconv"
 )
 
-func Device_all() []map[string]interface{} {
-	// Establish the database connection.
-	db, err := DB_connect()
+// known_tables lists the tables Truncate_table is allowed to empty.
+var known_tables = map[string]bool{
+	"switches":              true,
+	"interfaces":            true,
+	"interfaces_status":     true,
+	"power_modules":         true,
+	"power_interfaces":      true,
+	"vlans":                 true,
+	"cdp_neighbors":         true,
+	"lldp_neighbors":        true,
+	"mac_address_table":     true,
+	"show_running_config":   true,
+	"akips_interface_usage": true,
+	"vendors":               true,
+	"arp_table":             true,
+	"fqdn_table":            true,
+	"ise_ip_phones":         true,
+}
+
+// latest_interfaces restricts an UPDATE of interfaces (aliased i) to the latest run of each switch.
+// The grouped derived table is materialized, so MySQL allows it to read the table being updated.
+const latest_interfaces = "JOIN (SELECT switch_id, MAX(run_id) AS run_id FROM interfaces GROUP BY switch_id) latest ON latest.switch_id = i.switch_id AND latest.run_id = i.run_id"
+
+// latest_arp is the ARP table of the latest run of each gateway, with the rows stored without a run.
+const latest_arp = "(SELECT a.mac_address, a.ip_address FROM arp_table a LEFT JOIN (SELECT switch_id, MAX(run_id) AS run_id FROM arp_table GROUP BY switch_id) latest_arp " +
+	"ON latest_arp.switch_id = a.switch_id WHERE a.run_id IS NULL OR a.run_id = latest_arp.run_id) arp"
+
+func (s *Store) Device_all() []map[string]interface{} {
+	return s.Device_all_context(context.Background())
+}
+
+func (s *Store) Device_all_context(ctx context.Context) []map[string]interface{} {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT * from switches") // 'malloy-spine-1.hub.nd.edu','drni-n7010-itc.hub.nd.edu','lgomezreswitch.hub.nd.edu'
 	if err != nil {
-		log.Print(err)
+		log.Printf("Error reading data: %v", err)
 	}
-	defer db.Close()
 
-	rows, err := Return_query(db, "SELECT * from switches") // 'malloy-spine-1.hub.nd.edu','drni-n7010-itc.hub.nd.edu','lgomezreswitch.hub.nd.edu'
+	return rows
+}
+
+func (s *Store) Device_reachable() []map[string]interface{} {
+	return s.Device_reachable_context(context.Background())
+}
+
+func (s *Store) Device_reachable_context(ctx context.Context) []map[string]interface{} {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT count(*) as count from switches WHERE reachable = 1")
 	if err != nil {
 		log.Printf("Error reading data: %v", err)
 	}
@@ -21,15 +59,25 @@ func Device_all() []map[string]interface{} {
 	return rows
 }
 
-func Device_reachable() []map[string]interface{} {
-	// Establish the database connection.
-	db, err := DB_connect()
+func (s *Store) Device_unreachable() []map[string]interface{} {
+	return s.Device_unreachable_context(context.Background())
+}
+
+func (s *Store) Device_unreachable_context(ctx context.Context) []map[string]interface{} {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT count(*) as count from switches WHERE reachable = 0")
 	if err != nil {
-		log.Print(err)
+		log.Printf("Error reading data: %v", err)
 	}
-	defer db.Close()
 
-	rows, err := Return_query(db, "SELECT count(*) as count from switches WHERE reachable = 1")
+	return rows
+}
+
+func (s *Store) Device_by_id(switch_id int64) []map[string]interface{} {
+	return s.Device_by_id_context(context.Background(), switch_id)
+}
+
+func (s *Store) Device_by_id_context(ctx context.Context, switch_id int64) []map[string]interface{} {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT * from switches WHERE id = ?", switch_id)
 	if err != nil {
 		log.Printf("Error reading data: %v", err)
 	}
@@ -37,15 +85,40 @@ func Device_reachable() []map[string]interface{} {
 	return rows
 }
 
-func Device_unreachable() []map[string]interface{} {
-	// Establish the database connection.
-	db, err := DB_connect()
+func (s *Store) Interfaces_by_switch_id(switch_id int64) []map[string]interface{} {
+	return s.Interfaces_by_switch_id_context(context.Background(), switch_id)
+}
+
+func (s *Store) Interfaces_by_switch_id_context(ctx context.Context, switch_id int64) []map[string]interface{} {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT * from view_interfaces WHERE switch_id = ?", switch_id)
 	if err != nil {
-		log.Print(err)
+		log.Printf("Error reading data: %v", err)
 	}
-	defer db.Close()
 
-	rows, err := Return_query(db, "SELECT count(*) as count from switches WHERE reachable = 0")
+	return rows
+}
+
+func (s *Store) Vlan_names(switch_id int64) []map[string]interface{} {
+	return s.Vlan_names_context(context.Background(), switch_id)
+}
+
+func (s *Store) Vlan_names_context(ctx context.Context, switch_id int64) []map[string]interface{} {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT i.switch_id,i.interface,v.vlan_id,v.vlan_name FROM interfaces AS i JOIN vlans AS v ON i.switch_id = v.switch_id AND i.vlan_id = v.vlan_id AND i.run_id = v.run_id WHERE i.switch_id = ? AND i.run_id = (SELECT MAX(run_id) FROM interfaces WHERE switch_id = ?) AND FIND_IN_SET(i.interface, v.interfaces) > 0", switch_id, switch_id)
+	if err != nil {
+		log.Printf("Error reading data: %v", err)
+	}
+
+	return rows
+}
+
+func (s *Store) Mac_address_table_interfaces(switch_id int64) []map[string]interface{} {
+	return s.Mac_address_table_interfaces_context(context.Background(), switch_id)
+}
+
+// Mac_address_table_interfaces_context reads the trunk ports stored under the run of ctx,
+// or under the latest run of the switch when ctx carries none.
+func (s *Store) Mac_address_table_interfaces_context(ctx context.Context, switch_id int64) []map[string]interface{} {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT CONCAT(GROUP_CONCAT(CONCAT('_', interface, '_') SEPARATOR '|'),'|_CPU_') as interfaces from interfaces_status where switch_id = ? and status = 'connected' and vlan_id like '%trunk%' and run_id = COALESCE(?, (SELECT MAX(run_id) FROM interfaces_status WHERE switch_id = ?))", switch_id, run_arg(ctx), switch_id)
 	if err != nil {
 		log.Printf("Error reading data: %v", err)
 	}
@@ -53,218 +126,454 @@ func Device_unreachable() []map[string]interface{} {
 	return rows
 }
 
-func Device_by_id(switch_id string) []map[string]interface{} {
-	// Establish the database connection.
-	db, err := DB_connect()
+func (s *Store) Truncate_table(table_name string) {
+	s.Truncate_table_context(context.Background(), table_name)
+}
+
+func (s *Store) Truncate_table_context(ctx context.Context, table_name string) {
+	// Table names cannot be passed as placeholders, so only known tables are accepted.
+	if !known_tables[table_name] {
+		log.Printf("%s :: Error truncating table: %v", table_name, fmt.Errorf("unknown table"))
+		return
+	}
+
+	_, err := Execute_query_context(ctx, s.DB, "TRUNCATE TABLE `"+table_name+"`")
+	if err != nil {
+		log.Printf("%s :: Error truncating table: %v", table_name, err)
+	}
+
+}
+
+func (s *Store) Update_interfaces_mac_address() {
+	s.Update_interfaces_mac_address_context(context.Background())
+}
+
+func (s *Store) Update_interfaces_mac_address_context(ctx context.Context) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN (SELECT switch_id,interface,run_id,MIN(mac_address) AS mac_address FROM mac_address_table GROUP BY switch_id,interface,run_id) m ON i.switch_id = m.switch_id AND i.interface = m.interface AND i.run_id = m.run_id SET i.mac_address = m.mac_address")
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces mac_address: %v", "Interfaces mac_address", err)
+	}
+}
+func (s *Store) Update_interfaces_mac_address_by_switch_id(switch_id int64) {
+	s.Update_interfaces_mac_address_by_switch_id_context(context.Background(), switch_id)
+}
+
+func (s *Store) Update_interfaces_mac_address_by_switch_id_context(ctx context.Context, switch_id int64) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN (SELECT switch_id,interface,run_id,MIN(mac_address) AS mac_address FROM mac_address_table WHERE switch_id = ? GROUP BY switch_id,interface,run_id) m ON i.switch_id = m.switch_id AND i.interface = m.interface AND i.run_id = m.run_id SET i.mac_address = m.mac_address WHERE i.switch_id = ?", switch_id, switch_id)
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces mac_address: %v", "Interfaces mac_address", err)
+	}
+}
+
+func (s *Store) Update_interfaces_ip_address() {
+	s.Update_interfaces_ip_address_context(context.Background())
+}
+
+func (s *Store) Update_interfaces_ip_address_context(ctx context.Context) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN "+latest_arp+" ON i.mac_address = arp.mac_address SET i.ip_address = arp.ip_address")
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces ip_address: %v", "Interfaces ip_address", err)
+	}
+}
+
+func (s *Store) Update_interfaces_ip_address_by_switch_id(switch_id int64) {
+	s.Update_interfaces_ip_address_by_switch_id_context(context.Background(), switch_id)
+}
+
+func (s *Store) Update_interfaces_ip_address_by_switch_id_context(ctx context.Context, switch_id int64) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN "+latest_arp+" ON i.mac_address = arp.mac_address SET i.ip_address = arp.ip_address WHERE i.switch_id = ?", switch_id)
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces ip_address: %v", "Interfaces ip_address", err)
+	}
+}
+
+func (s *Store) Update_interfaces_fqdn() {
+	s.Update_interfaces_fqdn_context(context.Background())
+}
+
+func (s *Store) Update_interfaces_fqdn_context(ctx context.Context) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN fqdn_table ON i.mac_address = fqdn_table.mac_address AND i.ip_address = fqdn_table.ip_address SET i.fqdn = fqdn_table.fqdn")
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces fqdn: %v", "Interfaces fqdn", err)
+	}
+}
+
+func (s *Store) Update_interfaces_fqdn_by_switch_id(switch_id int64) {
+	s.Update_interfaces_fqdn_by_switch_id_context(context.Background(), switch_id)
+}
+
+func (s *Store) Update_interfaces_fqdn_by_switch_id_context(ctx context.Context, switch_id int64) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN fqdn_table ON i.mac_address = fqdn_table.mac_address AND i.ip_address = fqdn_table.ip_address SET i.fqdn = fqdn_table.fqdn WHERE i.switch_id = ?", switch_id)
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces fqdn: %v", "Interfaces fqdn", err)
+	}
+}
+
+func (s *Store) Update_interfaces_vlan_id() {
+	s.Update_interfaces_vlan_id_context(context.Background())
+}
+
+func (s *Store) Update_interfaces_vlan_id_context(ctx context.Context) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN interfaces_status ON i.switch_id = interfaces_status.switch_id AND i.interface = interfaces_status.interface AND i.run_id = interfaces_status.run_id SET i.vlan_id = interfaces_status.vlan_id")
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces vlan_id: %v", "Interfaces vlan_id", err)
+	}
+}
+func (s *Store) Update_interfaces_vlan_id_by_switch_id(switch_id int64) {
+	s.Update_interfaces_vlan_id_by_switch_id_context(context.Background(), switch_id)
+}
+
+func (s *Store) Update_interfaces_vlan_id_by_switch_id_context(ctx context.Context, switch_id int64) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN interfaces_status ON i.switch_id = interfaces_status.switch_id AND i.interface = interfaces_status.interface AND i.run_id = interfaces_status.run_id SET i.vlan_id = interfaces_status.vlan_id WHERE i.switch_id = ?", switch_id)
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces vlan_id: %v", "Interfaces vlan_id", err)
+	}
+}
+
+func (s *Store) Update_interfaces_vlan_name() {
+	s.Update_interfaces_vlan_name_context(context.Background())
+}
+
+func (s *Store) Update_interfaces_vlan_name_context(ctx context.Context) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN vlans ON i.switch_id = vlans.switch_id AND i.vlan_id = vlans.vlan_id AND i.run_id = vlans.run_id SET i.vlan_name = vlans.vlan_name")
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces vlan_id: %v", "Interfaces vlan_id", err)
+	}
+}
+func (s *Store) Update_interfaces_vlan_name_by_switch_id(switch_id int64) {
+	s.Update_interfaces_vlan_name_by_switch_id_context(context.Background(), switch_id)
+}
+
+func (s *Store) Update_interfaces_vlan_name_by_switch_id_context(ctx context.Context, switch_id int64) {
+	_, err := Execute_query_context(ctx, s.DB, "UPDATE interfaces i "+latest_interfaces+" JOIN vlans ON i.switch_id = vlans.switch_id AND i.vlan_id = vlans.vlan_id AND i.run_id = vlans.run_id SET i.vlan_name = vlans.vlan_name WHERE i.switch_id = ?", switch_id)
+	if err != nil {
+		log.Printf("%s :: Error updating interfaces vlan_id: %v", "Interfaces vlan_id", err)
+	}
+}
+
+// --- PACKAGE-LEVEL WRAPPERS ---
+// These keep the original API and run against Default_store.
+
+func Device_all() []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return nil
 	}
-	defer db.Close()
+	return s.Device_all()
+}
 
-	rows, err := Return_query(db, "SELECT * from switches WHERE id = "+switch_id)
+func Device_all_context(ctx context.Context) []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("Error reading data: %v", err)
+		log.Print(err)
+		return nil
 	}
+	return s.Device_all_context(ctx)
+}
 
-	return rows
+func Device_reachable() []map[string]interface{} {
+	s, err := Default_store()
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	return s.Device_reachable()
 }
 
-func Interfaces_by_switch_id(switch_id string) []map[string]interface{} {
-	// Establish the database connection.
-	db, err := DB_connect()
+func Device_reachable_context(ctx context.Context) []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return nil
 	}
-	defer db.Close()
+	return s.Device_reachable_context(ctx)
+}
 
-	rows, err := Return_query(db, "SELECT * from view_interfaces WHERE switch_id = "+switch_id)
+func Device_unreachable() []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("Error reading data: %v", err)
+		log.Print(err)
+		return nil
 	}
+	return s.Device_unreachable()
+}
 
-	return rows
+func Device_unreachable_context(ctx context.Context) []map[string]interface{} {
+	s, err := Default_store()
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	return s.Device_unreachable_context(ctx)
 }
 
-func Vlan_names(switch_id int64) []map[string]interface{} {
-	// Establish the database connection.
-	db, err := DB_connect()
+func Device_by_id(switch_id int64) []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return nil
 	}
-	defer db.Close()
+	return s.Device_by_id(switch_id)
+}
 
-	rows, err := Return_query(db, "SELECT i.switch_id,i.interface,v.vlan_id,v.vlan_name FROM interfaces AS i JOIN vlans AS v ON i.switch_id = v.switch_id AND i.vlan_id = v.vlan_id WHERE i.switch_id = "+strconv.FormatInt(switch_id, 10)+" AND FIND_IN_SET(i.interface, v.interfaces) > 0")
+func Device_by_id_context(ctx context.Context, switch_id int64) []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("Error reading data: %v", err)
+		log.Print(err)
+		return nil
 	}
+	return s.Device_by_id_context(ctx, switch_id)
+}
 
-	return rows
+func Interfaces_by_switch_id(switch_id int64) []map[string]interface{} {
+	s, err := Default_store()
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	return s.Interfaces_by_switch_id(switch_id)
 }
 
-func Mac_address_table_interfaces(switch_id int64) []map[string]interface{} {
-	// Establish the database connection.
-	db, err := DB_connect()
+func Interfaces_by_switch_id_context(ctx context.Context, switch_id int64) []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return nil
 	}
-	defer db.Close()
+	return s.Interfaces_by_switch_id_context(ctx, switch_id)
+}
 
-	rows, err := Return_query(db, "SELECT CONCAT(GROUP_CONCAT(CONCAT('_', interface, '_') SEPARATOR '|'),'|_CPU_') as interfaces from interfaces_status where switch_id = "+strconv.FormatInt(switch_id, 10)+" and status = 'connected' and vlan_id like '%trunk%' and date(created_at) = CURDATE()")
+func Vlan_names(switch_id int64) []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("Error reading data: %v", err)
+		log.Print(err)
+		return nil
 	}
+	return s.Vlan_names(switch_id)
+}
 
-	return rows
+func Vlan_names_context(ctx context.Context, switch_id int64) []map[string]interface{} {
+	s, err := Default_store()
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	return s.Vlan_names_context(ctx, switch_id)
 }
 
-func Truncate_table(table_name string) {
-	// Establish the database connection.
-	db, err := DB_connect()
+func Mac_address_table_interfaces(switch_id int64) []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return nil
 	}
-	defer db.Close()
+	return s.Mac_address_table_interfaces(switch_id)
+}
 
-	Execute_query(db, "TRUNCATE TABLE "+table_name)
+func Mac_address_table_interfaces_context(ctx context.Context, switch_id int64) []map[string]interface{} {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error truncating table: %v", table_name, err)
+		log.Print(err)
+		return nil
+	}
+	return s.Mac_address_table_interfaces_context(ctx, switch_id)
+}
+
+func Truncate_table(table_name string) {
+	s, err := Default_store()
+	if err != nil {
+		log.Print(err)
+		return
 	}
+	s.Truncate_table(table_name)
+}
 
+func Truncate_table_context(ctx context.Context, table_name string) {
+	s, err := Default_store()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	s.Truncate_table_context(ctx, table_name)
 }
 
 func Update_interfaces_mac_address() {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_mac_address()
+}
 
-	Execute_query(db, "UPDATE interfaces i JOIN (SELECT switch_id,interface,DATE(created_at) AS date_created,MIN(mac_address) AS mac_address FROM mac_address_table GROUP BY switch_id,interface,DATE(created_at)) m ON i.switch_id = m.switch_id AND i.interface = m.interface AND DATE(i.created_at) = m.date_created SET i.mac_address = m.mac_address WHERE DATE(i.created_at) = CURDATE()")
+func Update_interfaces_mac_address_context(ctx context.Context) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces mac_address: %v", "Interfaces mac_address", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_mac_address_context(ctx)
 }
+
 func Update_interfaces_mac_address_by_switch_id(switch_id int64) {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_mac_address_by_switch_id(switch_id)
+}
 
-	Execute_query(db, "UPDATE interfaces i JOIN (SELECT switch_id,interface,DATE(created_at) AS date_created,MIN(mac_address) AS mac_address FROM mac_address_table GROUP BY switch_id,interface,DATE(created_at)) m ON i.switch_id = m.switch_id AND i.interface = m.interface AND DATE(i.created_at) = m.date_created SET i.mac_address = m.mac_address WHERE i.switch_id = "+strconv.FormatInt(switch_id, 10)+" AND DATE(i.created_at) = CURDATE()")
+func Update_interfaces_mac_address_by_switch_id_context(ctx context.Context, switch_id int64) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces mac_address: %v", "Interfaces mac_address", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_mac_address_by_switch_id_context(ctx, switch_id)
 }
 
 func Update_interfaces_ip_address() {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_ip_address()
+}
 
-	Execute_query(db, "UPDATE interfaces JOIN arp_table ON interfaces.mac_address = arp_table.mac_address SET interfaces.ip_address = arp_table.ip_address WHERE DATE(interfaces.created_at) = CURDATE()")
+func Update_interfaces_ip_address_context(ctx context.Context) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces ip_address: %v", "Interfaces ip_address", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_ip_address_context(ctx)
 }
 
 func Update_interfaces_ip_address_by_switch_id(switch_id int64) {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_ip_address_by_switch_id(switch_id)
+}
 
-	Execute_query(db, "UPDATE interfaces JOIN arp_table ON interfaces.mac_address = arp_table.mac_address SET interfaces.ip_address = arp_table.ip_address WHERE interfaces.switch_id = "+strconv.FormatInt(switch_id, 10)+" AND DATE(interfaces.created_at) = CURDATE()")
+func Update_interfaces_ip_address_by_switch_id_context(ctx context.Context, switch_id int64) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces ip_address: %v", "Interfaces ip_address", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_ip_address_by_switch_id_context(ctx, switch_id)
 }
 
 func Update_interfaces_fqdn() {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_fqdn()
+}
 
-	Execute_query(db, "UPDATE interfaces JOIN fqdn_table ON interfaces.mac_address = fqdn_table.mac_address AND interfaces.ip_address = fqdn_table.ip_address SET interfaces.fqdn = fqdn_table.fqdn WHERE DATE(interfaces.created_at) = CURDATE()")
+func Update_interfaces_fqdn_context(ctx context.Context) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces fqdn: %v", "Interfaces fqdn", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_fqdn_context(ctx)
 }
 
 func Update_interfaces_fqdn_by_switch_id(switch_id int64) {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_fqdn_by_switch_id(switch_id)
+}
 
-	Execute_query(db, "UPDATE interfaces JOIN fqdn_table ON interfaces.mac_address = fqdn_table.mac_address AND interfaces.ip_address = fqdn_table.ip_address SET interfaces.fqdn = fqdn_table.fqdn WHERE interfaces.switch_id = "+strconv.FormatInt(switch_id, 10)+" AND DATE(interfaces.created_at) = CURDATE()")
+func Update_interfaces_fqdn_by_switch_id_context(ctx context.Context, switch_id int64) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces fqdn: %v", "Interfaces fqdn", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_fqdn_by_switch_id_context(ctx, switch_id)
 }
 
 func Update_interfaces_vlan_id() {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_vlan_id()
+}
 
-	Execute_query(db, "update interfaces join interfaces_status on interfaces.switch_id = interfaces_status.switch_id and interfaces.interface = interfaces_status.interface and date(interfaces.created_at) = date(interfaces_status.created_at) set interfaces.vlan_id = interfaces_status.vlan_id where date(interfaces.created_at) = curdate()")
+func Update_interfaces_vlan_id_context(ctx context.Context) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces vlan_id: %v", "Interfaces vlan_id", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_vlan_id_context(ctx)
 }
+
 func Update_interfaces_vlan_id_by_switch_id(switch_id int64) {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_vlan_id_by_switch_id(switch_id)
+}
 
-	Execute_query(db, "update interfaces join interfaces_status on interfaces.switch_id = interfaces_status.switch_id and interfaces.interface = interfaces_status.interface and date(interfaces.created_at) = date(interfaces_status.created_at) set interfaces.vlan_id = interfaces_status.vlan_id where interfaces.switch_id = "+strconv.FormatInt(switch_id, 10)+" AND date(interfaces.created_at) = curdate()")
+func Update_interfaces_vlan_id_by_switch_id_context(ctx context.Context, switch_id int64) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces vlan_id: %v", "Interfaces vlan_id", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_vlan_id_by_switch_id_context(ctx, switch_id)
 }
 
 func Update_interfaces_vlan_name() {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_vlan_name()
+}
 
-	Execute_query(db, "update interfaces join vlans on interfaces.switch_id = vlans.switch_id and interfaces.vlan_id = vlans.vlan_id and date(interfaces.created_at) = date(vlans.created_at) set interfaces.vlan_name = vlans.vlan_name where date(interfaces.created_at) = curdate()")
+func Update_interfaces_vlan_name_context(ctx context.Context) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces vlan_id: %v", "Interfaces vlan_id", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_vlan_name_context(ctx)
 }
+
 func Update_interfaces_vlan_name_by_switch_id(switch_id int64) {
-	// Establish the database connection.
-	db, err := DB_connect()
+	s, err := Default_store()
 	if err != nil {
 		log.Print(err)
+		return
 	}
-	defer db.Close()
+	s.Update_interfaces_vlan_name_by_switch_id(switch_id)
+}
 
-	Execute_query(db, "update interfaces join vlans on interfaces.switch_id = vlans.switch_id and interfaces.vlan_id = vlans.vlan_id and date(interfaces.created_at) = date(vlans.created_at) set interfaces.vlan_name = vlans.vlan_name where interfaces.switch_id = "+strconv.FormatInt(switch_id, 10)+" AND date(interfaces.created_at) = curdate()")
+func Update_interfaces_vlan_name_by_switch_id_context(ctx context.Context, switch_id int64) {
+	s, err := Default_store()
 	if err != nil {
-		log.Printf("%s :: Error updating interfaces vlan_id: %v", "Interfaces vlan_id", err)
+		log.Print(err)
+		return
 	}
+	s.Update_interfaces_vlan_name_by_switch_id_context(ctx, switch_id)
 }
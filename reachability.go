@@ -0,0 +1,306 @@
+package cisco_database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reachability states stored in switches.reachability and reachability_history.state.
+const (
+	ReachReachable  = "reachable"
+	ReachAuthFailed = "auth_failed"
+	ReachTimeout    = "timeout"
+	ReachDNSFailed  = "dns_failed"
+	ReachRefused    = "refused"
+	ReachError      = "error"
+)
+
+// ssh_port is the port the collectors and the probes connect to.
+const ssh_port = "22"
+
+// probe_timeout bounds the TCP connect and the SSH handshake of Probe_switch.
+const probe_timeout = 10 * time.Second
+
+// Reachability is the outcome of one SSH probe of a switch.
+type Reachability struct {
+	State   string
+	Err     error
+	Latency time.Duration
+}
+
+// Probe_switch opens an SSH session to the switch with the CISCO_USERNAME and CISCO_PASSWORD
+// credentials used by the collectors, and reports whether it succeeded or why it did not.
+func (s *Store) Probe_switch(ctx context.Context, fqdn string) Reachability {
+	start := time.Now()
+	err := probe_ssh(ctx, fqdn)
+	return Reachability{State: Reachability_state(err), Err: err, Latency: time.Since(start)}
+}
+
+// Probe_port only connects to a TCP port of the switch, ssh_port for the collectors, without logging in.
+// It tells a switch that is down, unresolvable or refusing SSH from one that answers, at no TACACS or VTY cost.
+func (s *Store) Probe_port(ctx context.Context, fqdn string, port string) Reachability {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, probe_timeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(fqdn, port))
+	if err == nil {
+		conn.Close()
+	}
+	return Reachability{State: Reachability_state(err), Err: err, Latency: time.Since(start)}
+}
+
+// device_login holds the outcome of the first device command sent to a switch during
+// Run_collectors. Steps that send no command (Akips, compliance, change detection, ARP on
+// a non-gateway) leave it unset, so they never stand in for a login.
+type device_login struct {
+	fqdn string
+
+	mu   sync.Mutex
+	done bool
+	err  error
+}
+
+type device_login_key struct{}
+
+// with_device_login returns a context whose device commands to fqdn record their outcome in the returned device_login.
+func with_device_login(ctx context.Context, fqdn string) (context.Context, *device_login) {
+	login := &device_login{fqdn: fqdn}
+	return context.WithValue(ctx, device_login_key{}, login), login
+}
+
+// record_device_login keeps the outcome of a device command to switch_hostname when it is the first one.
+func record_device_login(ctx context.Context, switch_hostname string, err error) {
+	login, _ := ctx.Value(device_login_key{}).(*device_login)
+	if login == nil || login.fqdn != switch_hostname {
+		return
+	}
+	login.mu.Lock()
+	defer login.mu.Unlock()
+	if !login.done {
+		login.done, login.err = true, err
+	}
+}
+
+// outcome returns the error of the first device command, and whether a command was sent at all.
+func (login *device_login) outcome() (error, bool) {
+	login.mu.Lock()
+	defer login.mu.Unlock()
+	return login.err, login.done
+}
+
+// login_reachability completes a Probe_port with the outcome of the first device command:
+// an auth, timeout, refused or dns failure is a reachability failure; a command that
+// logged in, even when its output then failed to parse, means the switch is reachable.
+func login_reachability(port Reachability, err error) Reachability {
+	probe := Reachability{State: ReachReachable, Latency: port.Latency}
+	if err == nil {
+		return probe
+	}
+	switch Classify_error(err) {
+	case ErrorAuth:
+		probe.State = ReachAuthFailed
+	case ErrorTimeout:
+		probe.State = ReachTimeout
+	case ErrorRefused:
+		probe.State = ReachRefused
+	case ErrorDNS:
+		probe.State = ReachDNSFailed
+	case ErrorOther:
+		probe.State = ReachError
+	}
+	if probe.State != ReachReachable {
+		probe.Err = err
+	}
+	return probe
+}
+
+func probe_ssh(ctx context.Context, fqdn string) error {
+	ctx, cancel := context.WithTimeout(ctx, probe_timeout)
+	defer cancel()
+
+	client, err := dial_ssh(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+// Reachability_state maps an SSH connection error to a reachability state, ReachReachable for nil.
+func Reachability_state(err error) string {
+	if err == nil {
+		return ReachReachable
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ReachTimeout
+		}
+		return ReachDNSFailed
+	}
+
+	message := err.Error()
+	if strings.Contains(message, "unable to authenticate") || strings.Contains(message, "no supported methods remain") {
+		return ReachAuthFailed
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) || strings.Contains(message, "timed out") {
+		return ReachTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(message, "connection refused") {
+		return ReachRefused
+	}
+	return ReachError
+}
+
+// record_reachability stores the probe on the switch and in reachability_history.
+// reachable is kept at 1 or 0 for Device_reachable and Device_unreachable.
+func (s *Store) record_reachability(ctx context.Context, switch_id int64, probe Reachability) error {
+	now := time.Now().UTC()
+	var lastError any
+	if probe.Err != nil {
+		lastError = probe.Err.Error()
+	}
+	reachable := 0
+	if probe.State == ReachReachable {
+		reachable = 1
+	}
+
+	// The probe is recorded even when ctx was cancelled during the collection.
+	ctx = context.WithoutCancel(ctx)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// last_seen_at only moves on success, last_error keeps the latest failure.
+	_, err = tx.ExecContext(ctx, "UPDATE `switches` SET `reachable` = ?, `reachability` = ?, `last_checked_at` = ?, "+
+		"`last_seen_at` = IF(? = 1, ?, `last_seen_at`), `last_error` = IF(? = 1, `last_error`, ?) WHERE `id` = ?",
+		reachable, probe.State, now, reachable, now, reachable, lastError, switch_id)
+	if err != nil {
+		return fmt.Errorf("error recording reachability of switch %d: %w", switch_id, err)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO `reachability_history` (`switch_id`, `run_id`, `state`, `error`, `latency_ms`, `checked_at`) VALUES (?, ?, ?, ?, ?, ?)",
+		switch_id, run_arg(ctx), probe.State, lastError, probe.Latency.Milliseconds(), now)
+	if err != nil {
+		return fmt.Errorf("error recording reachability of switch %d: %w", switch_id, err)
+	}
+	return tx.Commit()
+}
+
+// ReachabilityCheck is one row of reachability_history.
+type ReachabilityCheck struct {
+	SwitchID  int64
+	RunID     int64
+	State     string
+	Error     string
+	LatencyMs int64
+	CheckedAt time.Time
+}
+
+// Reachability_history returns the checks of a switch since the given time, oldest first.
+func (s *Store) Reachability_history(ctx context.Context, switch_id int64, since time.Time) ([]ReachabilityCheck, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT `switch_id`, `run_id`, `state`, `error`, `latency_ms`, `checked_at` FROM `reachability_history` "+
+		"WHERE `switch_id` = ? AND `checked_at` >= ? ORDER BY `checked_at`, `id`", switch_id, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error reading reachability history: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []ReachabilityCheck
+	for rows.Next() {
+		var check ReachabilityCheck
+		var runID, latency sql.NullInt64
+		var checkErr sql.NullString
+		if err := rows.Scan(&check.SwitchID, &runID, &check.State, &checkErr, &latency, &check.CheckedAt); err != nil {
+			return nil, fmt.Errorf("error reading reachability history: %w", err)
+		}
+		check.RunID, check.Error, check.LatencyMs = runID.Int64, checkErr.String, latency.Int64
+		checks = append(checks, check)
+	}
+	return checks, rows.Err()
+}
+
+// FlappingSwitch counts the state changes of a switch in Flapping_switches.
+type FlappingSwitch struct {
+	SwitchID int64
+	Fqdn     string
+	Checks   int64
+	Changes  int64
+	State    string
+}
+
+// Flapping_switches returns the switches whose reachability changed at least min_changes
+// times since the given time, most changes first.
+func (s *Store) Flapping_switches(ctx context.Context, since time.Time, min_changes int64) ([]FlappingSwitch, error) {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT h.`switch_id`, s.`fqdn`, h.`state` FROM `reachability_history` h "+
+		"JOIN `switches` s ON s.`id` = h.`switch_id` WHERE h.`checked_at` >= ? ORDER BY h.`switch_id`, h.`checked_at`, h.`id`", since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error reading reachability history: %w", err)
+	}
+
+	var switches []FlappingSwitch
+	var current *FlappingSwitch
+	for _, row := range rows {
+		switch_id := Row_int64(row, "switch_id")
+		state := Row_string(row, "state")
+		if current == nil || current.SwitchID != switch_id {
+			switches = append(switches, FlappingSwitch{SwitchID: switch_id, Fqdn: Row_string(row, "fqdn"), State: state})
+			current = &switches[len(switches)-1]
+		} else if current.State != state {
+			current.Changes++
+			current.State = state
+		}
+		current.Checks++
+	}
+
+	flapping := switches[:0]
+	for _, sw := range switches {
+		if sw.Changes >= min_changes {
+			flapping = append(flapping, sw)
+		}
+	}
+	slices.SortStableFunc(flapping, func(a, b FlappingSwitch) int { return cmp.Compare(b.Changes, a.Changes) })
+	return flapping, nil
+}
+
+// Probe_switch runs s.Probe_switch against Default_store.
+func Probe_switch(ctx context.Context, fqdn string) Reachability {
+	s, err := Default_store()
+	if err != nil {
+		return Reachability{State: ReachError, Err: err}
+	}
+	return s.Probe_switch(ctx, fqdn)
+}
+
+// Reachability_history runs s.Reachability_history against Default_store.
+func Reachability_history(ctx context.Context, switch_id int64, since time.Time) ([]ReachabilityCheck, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Reachability_history(ctx, switch_id, since)
+}
+
+// Flapping_switches runs s.Flapping_switches against Default_store.
+func Flapping_switches(ctx context.Context, since time.Time, min_changes int64) ([]FlappingSwitch, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Flapping_switches(ctx, since, min_changes)
+}
@@ -0,0 +1,86 @@
+package cisco_database
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestProbePort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	_, open, _ := net.SplitHostPort(listener.Addr().String())
+
+	// A port that was just released has no listener and refuses the connection.
+	closing, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, closed, _ := net.SplitHostPort(closing.Addr().String())
+	closing.Close()
+
+	s := &Store{}
+	if probe := s.Probe_port(context.Background(), "127.0.0.1", open); probe.State != ReachReachable {
+		t.Errorf("Probe_port on a listener = %s (%v), want %s", probe.State, probe.Err, ReachReachable)
+	}
+	if probe := s.Probe_port(context.Background(), "127.0.0.1", closed); probe.State != ReachRefused {
+		t.Errorf("Probe_port on a closed port = %s (%v), want %s", probe.State, probe.Err, ReachRefused)
+	}
+}
+
+func TestLoginReachability(t *testing.T) {
+	port := Reachability{State: ReachReachable}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ReachReachable},
+		{&CollectError{Class: ErrorParse, Err: errors.New("could not find VLAN header in output")}, ReachReachable},
+		{&CollectError{Class: ErrorAuth, Err: errors.New("ssh: unable to authenticate")}, ReachAuthFailed},
+		{&CollectError{Class: ErrorTimeout, Err: errors.New("i/o timeout")}, ReachTimeout},
+		{&CollectError{Class: ErrorRefused, Err: errors.New("connection refused")}, ReachRefused},
+		{&CollectError{Class: ErrorDNS, Err: errors.New("no such host")}, ReachDNSFailed},
+		{&CollectError{Class: ErrorOther, Err: errors.New("EOF")}, ReachError},
+	}
+	for _, tt := range tests {
+		got := login_reachability(port, tt.err)
+		if got.State != tt.want {
+			t.Errorf("%v: state = %s, want %s", tt.err, got.State, tt.want)
+		}
+		if (got.Err != nil) != (tt.want != ReachReachable) {
+			t.Errorf("%v: err = %v", tt.err, got.Err)
+		}
+	}
+}
+
+func TestDeviceLogin(t *testing.T) {
+	policy := RetryPolicy{Attempts: 1}
+	ctx, login := with_device_login(context.Background(), "sw1.example.com")
+
+	// Steps that send no device command, or send it to another switch, leave the login unset.
+	retry_command(ctx, policy, "gw1.example.com", "show ip arp", func() (string, error) { return "", nil })
+	if _, sent := login.outcome(); sent {
+		t.Fatalf("a command to another switch was recorded")
+	}
+
+	_, err := retry_command(ctx, policy, "sw1.example.com", "show version", func() (string, error) {
+		return "", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password]")
+	})
+	loginErr, sent := login.outcome()
+	if !sent || !errors.Is(loginErr, err) {
+		t.Fatalf("outcome = %v, %v, want the auth failure", loginErr, sent)
+	}
+	if got := login_reachability(Reachability{}, loginErr).State; got != ReachAuthFailed {
+		t.Errorf("state = %s, want %s", got, ReachAuthFailed)
+	}
+
+	// Only the first command counts.
+	retry_command(ctx, policy, "sw1.example.com", "show vlan", func() (string, error) { return "", nil })
+	if loginErr, _ := login.outcome(); loginErr == nil {
+		t.Errorf("a later command replaced the first outcome")
+	}
+}
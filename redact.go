@@ -0,0 +1,130 @@
+package cisco_database
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Redacted replaces the secrets removed by the built-in redaction rules.
+const Redacted = "<redacted>"
+
+// RedactionRule replaces the matches of Pattern in a configuration, line by line
+// ((?m) is implied, so ^ and $ match at line boundaries).
+type RedactionRule struct {
+	Name    string `yaml:"name" toml:"name"`
+	Pattern string `yaml:"pattern" toml:"pattern"`
+	// Replacement may refer to the groups of Pattern as ${1}. Default "<redacted>" replaces the whole match.
+	Replacement string `yaml:"replacement" toml:"replacement"`
+
+	// keywords are values of the second group that are part of the command, not a secret;
+	// a match whose second group is one of them is left as is.
+	keywords []string
+}
+
+// RedactionConfig adds rules to the built-in ones, or replaces them when DisableBuiltin is set.
+type RedactionConfig struct {
+	DisableBuiltin bool            `yaml:"disable_builtin" toml:"disable_builtin"`
+	Rules          []RedactionRule `yaml:"rules" toml:"rules"`
+}
+
+// Builtin_redaction_rules returns the rules for the secret lines of IOS, IOS-XE and NX-OS.
+// Each keeps the command and the encryption type and replaces the secret itself.
+// The patterns use [ \t] rather than \s so that no rule reaches into the next line.
+func Builtin_redaction_rules() []RedactionRule {
+	keep := "${1}" + Redacted
+	return []RedactionRule{
+		{Name: "enable", Pattern: `^([ \t]*enable[ \t]+(?:secret|password)[ \t]+(?:level[ \t]+\d+[ \t]+)?(?:\d+[ \t]+)?)\S+`, Replacement: keep},
+		{Name: "username", Pattern: `^([ \t]*username[ \t]+\S+[ \t]+(?:.*?[ \t])?(?:secret|password)[ \t]+(?:\d+[ \t]+)?)\S+`, Replacement: keep},
+		{Name: "line password", Pattern: `^([ \t]*password[ \t]+(?:\d+[ \t]+)?)\S+`, Replacement: keep},
+		{Name: "snmp community", Pattern: `^([ \t]*snmp-server[ \t]+community[ \t]+)\S+`, Replacement: keep},
+		// Covers the v1 form without a version keyword; on a version 3 line the token reached is "version", left as is.
+		{Name: "snmp host community", Pattern: `^([ \t]*snmp-server[ \t]+host[ \t]+\S+[ \t]+(?:vrf[ \t]+\S+[ \t]+)?(?:(?:informs|traps)[ \t]+)?(?:version[ \t]+(?:1|2c)[ \t]+)?)(\S+)`, Replacement: keep, keywords: []string{"version"}},
+		{Name: "snmp user auth", Pattern: `^([ \t]*snmp-server[ \t]+user[ \t].*?[ \t]auth[ \t]+\S+[ \t]+)\S+`, Replacement: keep},
+		{Name: "snmp user priv", Pattern: `^([ \t]*snmp-server[ \t]+user[ \t].*?[ \t]priv[ \t]+(?:(?:aes|des|3des)(?:-\d+)?[ \t]+(?:\d+[ \t]+)?)?)\S+`, Replacement: keep},
+		{Name: "tacacs radius key", Pattern: `^([ \t]*(?:tacacs-server|radius-server)[ \t].*?\bkey[ \t]+(?:\d+[ \t]+)?)("[^"]*"|\S+)`, Replacement: keep},
+		// Before "server key", so the key-string line of a key chain is never taken for a server key.
+		{Name: "key-string", Pattern: `((?:^|[ \t])key-string[ \t]+(?:\d+[ \t]+)?)\S+`, Replacement: keep},
+		// The key line of a radius/tacacs server or dynamic-author block, not the key id of a key chain.
+		{Name: "server key", Pattern: `^((?:radius[ \t]+server|tacacs[ \t]+server|aaa[ \t]+server[ \t]+radius[ \t]+dynamic-author)\b[^\n]*\n(?:[ \t]+[^\n]*\n)*?[ \t]+(?:server-)?key[ \t]+(?:[0-7][ \t]+)?)("[^"]*"|\S+)`, Replacement: keep},
+		{Name: "inline server key", Pattern: `^([ \t]+(?:server-private|client)[ \t]+\S+[ \t].*?\b(?:server-)?key[ \t]+(?:[0-7][ \t]+)?)("[^"]*"|\S+)`, Replacement: keep},
+		{Name: "isakmp key", Pattern: `^([ \t]*crypto[ \t]+isakmp[ \t]+key[ \t]+(?:\d+[ \t]+)?)\S+`, Replacement: keep},
+		{Name: "pre-shared-key", Pattern: `^([ \t]*pre-shared-key[ \t]+(?:(?:local|remote)[ \t]+)?(?:\d+[ \t]+)?)\S+`, Replacement: keep},
+		{Name: "neighbor password", Pattern: `^([ \t]*neighbor[ \t]+\S+[ \t]+password[ \t]+(?:\d+[ \t]+)?)\S+`, Replacement: keep},
+		{Name: "ospf key", Pattern: `^([ \t]*ip[ \t]+ospf[ \t]+(?:message-digest-key[ \t]+\d+[ \t]+md5|authentication-key)[ \t]+(?:\d+[ \t]+)?)\S+`, Replacement: keep},
+		{Name: "ntp key", Pattern: `^([ \t]*ntp[ \t]+authentication-key[ \t]+\d+[ \t]+md5[ \t]+)\S+`, Replacement: keep},
+	}
+}
+
+// Redactor removes secrets from device output before it is stored or exported.
+type Redactor struct {
+	rules []compiled_rule
+}
+
+type compiled_rule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+	keywords    []string
+}
+
+// New_redactor compiles the built-in rules, unless disabled, followed by the rules of cfg.
+func New_redactor(cfg RedactionConfig) (*Redactor, error) {
+	var rules []RedactionRule
+	if !cfg.DisableBuiltin {
+		rules = Builtin_redaction_rules()
+	}
+	rules = append(rules, cfg.Rules...)
+
+	r := &Redactor{}
+	for i, rule := range rules {
+		re, err := regexp.Compile("(?m)" + rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling redaction rule %d %q: %w", i+1, rule.Name, err)
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = Redacted
+		}
+		r.rules = append(r.rules, compiled_rule{name: rule.Name, re: re, replacement: replacement, keywords: rule.keywords})
+	}
+	return r, nil
+}
+
+// Default_redactor returns a Redactor with the built-in rules only.
+func Default_redactor() *Redactor {
+	r, err := New_redactor(RedactionConfig{})
+	if err != nil {
+		// The built-in rules are constants, a failure is a bug.
+		panic(err)
+	}
+	return r
+}
+
+// Redact returns text with every rule applied.
+func (r *Redactor) Redact(text string) string {
+	for _, rule := range r.rules {
+		if len(rule.keywords) == 0 {
+			text = rule.re.ReplaceAllString(text, rule.replacement)
+			continue
+		}
+		text = rule.re.ReplaceAllStringFunc(text, func(match string) string {
+			groups := rule.re.FindStringSubmatchIndex(match)
+			if len(groups) >= 6 && groups[4] >= 0 && slices.Contains(rule.keywords, match[groups[4]:groups[5]]) {
+				return match
+			}
+			return string(rule.re.ExpandString(nil, rule.replacement, match, groups))
+		})
+	}
+	return text
+}
+
+// redact applies the Store Redactor, the built-in rules when none is set.
+func (s *Store) redact(text string) string {
+	if s.Redactor == nil {
+		return default_redactor.Redact(text)
+	}
+	return s.Redactor.Redact(text)
+}
+
+var default_redactor = Default_redactor()
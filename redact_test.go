@@ -0,0 +1,96 @@
+package cisco_database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinRedactionRules(t *testing.T) {
+	tests := []struct {
+		rule string
+		in   string
+		want string
+	}{
+		{"enable", "enable secret 9 $9$abcdef", "enable secret 9 <redacted>"},
+		{"enable", "enable password level 15 7 0822455D0A16", "enable password level 15 7 <redacted>"},
+		{"username", "username admin privilege 15 secret 9 $9$xyz", "username admin privilege 15 secret 9 <redacted>"},
+		{"username", "username ops password 0 plain", "username ops password 0 <redacted>"},
+		{"line password", "line vty 0 4\n password 7 0822455D0A16\n login", "line vty 0 4\n password 7 <redacted>\n login"},
+		{"snmp community", "snmp-server community PUBLIC RO 10", "snmp-server community <redacted> RO 10"},
+		{"snmp host community", "snmp-server host 10.1.1.1 version 2c MYCOMM", "snmp-server host 10.1.1.1 version 2c <redacted>"},
+		{"snmp host community", "snmp-server host 10.1.1.1 informs version 2c MYCOMM config", "snmp-server host 10.1.1.1 informs version 2c <redacted> config"},
+		{"snmp host community", "snmp-server host 10.1.1.1 MYCOMM", "snmp-server host 10.1.1.1 <redacted>"},
+		{"snmp host community", "snmp-server host 10.1.1.1 traps MYCOMM", "snmp-server host 10.1.1.1 traps <redacted>"},
+		{"snmp host community", "snmp-server host 10.1.1.1 vrf MGMT MYCOMM", "snmp-server host 10.1.1.1 vrf MGMT <redacted>"},
+		{"snmp host community", "snmp-server host 10.1.1.1 version 3 priv snmpuser", "snmp-server host 10.1.1.1 version 3 priv snmpuser"},
+		{"snmp user auth", "snmp-server user u1 grp v3 auth sha AUTHPASS", "snmp-server user u1 grp v3 auth sha <redacted>"},
+		{"snmp user priv", "snmp-server user u1 grp v3 auth sha AUTHPASS priv aes 128 PRIVPASS", "snmp-server user u1 grp v3 auth sha <redacted> priv aes 128 <redacted>"},
+		{"tacacs radius key", "tacacs-server host 10.0.0.1 key 7 0822455D0A16", "tacacs-server host 10.0.0.1 key 7 <redacted>"},
+		{"tacacs radius key", `radius-server key "my secret"`, "radius-server key <redacted>"},
+		{"key-string", "key chain OSPF\n key 1\n  key-string 7 0822455D0A16\n key 2\n  key-string PLAIN", "key chain OSPF\n key 1\n  key-string 7 <redacted>\n key 2\n  key-string <redacted>"},
+		{"key-string", " ip rip authentication key-string SECRET", " ip rip authentication key-string <redacted>"},
+		{"server key", "radius server ISE\n address ipv4 10.0.0.1 auth-port 1812 acct-port 1813\n key 7 0822455D0A16\n!", "radius server ISE\n address ipv4 10.0.0.1 auth-port 1812 acct-port 1813\n key 7 <redacted>\n!"},
+		{"server key", "tacacs server TAC1\n address ipv4 10.0.0.2\n key SECRET", "tacacs server TAC1\n address ipv4 10.0.0.2\n key <redacted>"},
+		{"server key", "aaa server radius dynamic-author\n server-key 7 0822455D0A16", "aaa server radius dynamic-author\n server-key 7 <redacted>"},
+		{"inline server key", " client 10.0.0.1 server-key 7 0822455D0A16", " client 10.0.0.1 server-key 7 <redacted>"},
+		{"inline server key", " server-private 10.0.0.1 auth-port 1812 key 7 0822455D0A16", " server-private 10.0.0.1 auth-port 1812 key 7 <redacted>"},
+		{"isakmp key", "crypto isakmp key 6 SECRET address 192.0.2.1", "crypto isakmp key 6 <redacted> address 192.0.2.1"},
+		{"pre-shared-key", " pre-shared-key local 6 SECRET", " pre-shared-key local 6 <redacted>"},
+		{"neighbor password", " neighbor 10.0.0.2 password 7 0822455D0A16", " neighbor 10.0.0.2 password 7 <redacted>"},
+		{"ospf key", " ip ospf message-digest-key 1 md5 7 0822455D0A16", " ip ospf message-digest-key 1 md5 7 <redacted>"},
+		{"ospf key", " ip ospf authentication-key 7 0822455D0A16", " ip ospf authentication-key 7 <redacted>"},
+		{"ntp key", "ntp authentication-key 1 md5 0822455D0A16 7", "ntp authentication-key 1 md5 <redacted> 7"},
+	}
+
+	r := Default_redactor()
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[tt.rule] = true
+		if got := r.Redact(tt.in); got != tt.want {
+			t.Errorf("%s: Redact(%q)\n got %q\nwant %q", tt.rule, tt.in, got, tt.want)
+		}
+	}
+	for _, rule := range Builtin_redaction_rules() {
+		if !covered[rule.Name] {
+			t.Errorf("built-in rule %q has no test case", rule.Name)
+		}
+	}
+}
+
+func TestRedactionRulesStayOnTheirLine(t *testing.T) {
+	// No secret line may be changed by a rule matching the line before it.
+	config := strings.Join([]string{
+		"key chain EIGRP",
+		" key 1",
+		"  key-string 7 0822455D0A16",
+		"snmp-server community",
+		"PUBLIC",
+		"enable secret",
+		"5 notasecret",
+	}, "\n")
+	want := strings.Join([]string{
+		"key chain EIGRP",
+		" key 1",
+		"  key-string 7 <redacted>",
+		"snmp-server community",
+		"PUBLIC",
+		"enable secret",
+		"5 notasecret",
+	}, "\n")
+	if got := Default_redactor().Redact(config); got != want {
+		t.Errorf("Redact\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestRedactionCustomRule(t *testing.T) {
+	r, err := New_redactor(RedactionConfig{Rules: []RedactionRule{{Name: "wpa", Pattern: `^([ \t]*wpa-psk ascii[ \t]+)\S+`, Replacement: "${1}<redacted>"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Redact(" wpa-psk ascii SECRET"), " wpa-psk ascii <redacted>"; got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+	if _, err := New_redactor(RedactionConfig{Rules: []RedactionRule{{Name: "bad", Pattern: `(`}}}); err == nil {
+		t.Error("New_redactor accepted an invalid pattern")
+	}
+}
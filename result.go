@@ -0,0 +1,115 @@
+package cisco_database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StepStatus is the outcome of one collection step.
+type StepStatus string
+
+const (
+	StepOK      StepStatus = "ok"
+	StepFailed  StepStatus = "failed"
+	StepSkipped StepStatus = "skipped"
+	// StepDisabled marks a collector disabled in the Registry for this switch.
+	StepDisabled StepStatus = "disabled"
+)
+
+// ProcessOptions controls how Process_switch_result runs the collection steps.
+type ProcessOptions struct {
+	// ContinueOnError runs every step even when an earlier one failed.
+	ContinueOnError bool
+	// SkipReachability runs the steps without probing SSH first and recording the switch reachability.
+	SkipReachability bool
+}
+
+// StepResult describes one collection step of a switch.
+type StepResult struct {
+	Name     string
+	Status   StepStatus
+	Duration time.Duration
+	Rows     int64
+	Err      error
+	// Class is the Classify_error class of Err.
+	Class string
+}
+
+// SwitchResult describes every collection step run for a switch.
+type SwitchResult struct {
+	SwitchID int64
+	Fqdn     string
+	Started  time.Time
+	Duration time.Duration
+	Steps    []StepResult
+	// RunID is the collection run the rows were stored under.
+	RunID int64
+}
+
+// OK reports whether no step failed.
+func (result SwitchResult) OK() bool {
+	return len(result.Failed()) == 0
+}
+
+// Failed returns the steps that failed.
+func (result SwitchResult) Failed() []StepResult {
+	var failed []StepResult
+	for _, step := range result.Steps {
+		if step.Status == StepFailed {
+			failed = append(failed, step)
+		}
+	}
+	return failed
+}
+
+// Step returns the result of the named step.
+func (result SwitchResult) Step(name string) (StepResult, bool) {
+	for _, step := range result.Steps {
+		if step.Name == name {
+			return step, true
+		}
+	}
+	return StepResult{}, false
+}
+
+// Rows returns the number of rows stored by all steps.
+func (result SwitchResult) Rows() int64 {
+	var rows int64
+	for _, step := range result.Steps {
+		rows += step.Rows
+	}
+	return rows
+}
+
+// Err joins the errors of the failed steps, each prefixed with the step name.
+// It returns nil when every step succeeded.
+func (result SwitchResult) Err() error {
+	var errs []error
+	for _, step := range result.Failed() {
+		errs = append(errs, fmt.Errorf("[%s] %w", step.Name, step.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// Status returns the collection_runs status matching the result.
+func (result SwitchResult) Status() string {
+	switch {
+	case result.OK():
+		return RunSucceeded
+	case result.succeeded_steps() > 0:
+		return RunPartial
+	default:
+		return RunFailed
+	}
+}
+
+func (result SwitchResult) succeeded_steps() int {
+	count := 0
+	for _, step := range result.Steps {
+		if step.Status == StepOK {
+			count++
+		}
+	}
+	return count
+}
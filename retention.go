@@ -0,0 +1,173 @@
+package cisco_database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// retention_tables are the tables whose rows belong to a collection run.
+var retention_tables = []string{
+	"interfaces",
+	"interfaces_status",
+	"power_modules",
+	"power_interfaces",
+	"vlans",
+	"cdp_neighbors",
+	"lldp_neighbors",
+	"mac_address_table",
+	"show_running_config",
+	"akips_interface_usage",
+	"arp_table",
+	"inventory",
+}
+
+// retention_history_tables are the tables pruned by age alone, with the column holding the age.
+var retention_history_tables = map[string]string{
+	"reachability_history": "checked_at",
+}
+
+// RetentionPolicy controls how many days of snapshots Prune keeps in each table.
+// The latest run of every switch is always kept, however old it is.
+type RetentionPolicy struct {
+	// DefaultDays applies to the tables not listed in Tables. 0 keeps them forever.
+	DefaultDays int `yaml:"default_days" toml:"default_days"`
+	// Tables overrides DefaultDays per table, e.g. {"show_running_config": 365}. 0 keeps the table forever.
+	Tables map[string]int `yaml:"tables" toml:"tables"`
+	// BatchSize is the number of rows deleted per statement. Default 5000.
+	BatchSize int `yaml:"batch_size" toml:"batch_size"`
+}
+
+// Default_retention_policy keeps 30 days of snapshots, a year of running configs for audits
+// and 90 days of reachability history.
+func Default_retention_policy() RetentionPolicy {
+	return RetentionPolicy{
+		DefaultDays: 30,
+		Tables:      map[string]int{"show_running_config": 365, "reachability_history": 90},
+		BatchSize:   5000,
+	}
+}
+
+// Days returns the number of days kept in table, 0 meaning forever.
+func (policy RetentionPolicy) Days(table string) int {
+	if days, ok := policy.Tables[table]; ok {
+		return days
+	}
+	return policy.DefaultDays
+}
+
+// PruneResult reports what Prune deleted.
+type PruneResult struct {
+	// Deleted is the number of rows deleted per table.
+	Deleted map[string]int64
+	// Runs is the number of collection_runs rows deleted once no table referred to them.
+	Runs     int64
+	Duration time.Duration
+}
+
+// Prune deletes the snapshots older than the policy allows, in batches of policy.BatchSize
+// rows so it never holds long locks on tables the collectors are writing to.
+// Rows collected before collection runs existed are aged by created_at.
+func (s *Store) Prune(ctx context.Context, policy RetentionPolicy) (PruneResult, error) {
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = 5000
+	}
+	for table := range policy.Tables {
+		if _, history := retention_history_tables[table]; !history && !slices.Contains(retention_tables, table) {
+			return PruneResult{}, fmt.Errorf("error: retention policy names unknown table %s", table)
+		}
+	}
+
+	start := time.Now()
+	result := PruneResult{Deleted: make(map[string]int64)}
+	now := time.Now().UTC()
+
+	for _, table := range retention_tables {
+		days := policy.Days(table)
+		if days <= 0 {
+			continue
+		}
+		cutoff := now.AddDate(0, 0, -days)
+
+		var lastRun int64
+		rows, err := Return_query_context(ctx, s.DB, "SELECT COALESCE(MAX(`id`), 0) AS id FROM `collection_runs` WHERE `started_at` < ?", cutoff)
+		if err != nil {
+			return result, fmt.Errorf("error reading collection runs: %w", err)
+		}
+		if len(rows) > 0 {
+			lastRun = Row_int64(rows[0], "id")
+		}
+
+		// The latest run of each switch is read through a grouped derived table,
+		// which MySQL materializes so the DELETE may read the table it deletes from.
+		// A row without a switch_id (an ARP entry whose source matched no switch) belongs to no
+		// latest run; NOT IN alone would be NULL for it and keep it forever.
+		query := "DELETE FROM `" + table + "` WHERE (`run_id` <= ? OR (`run_id` IS NULL AND `created_at` < ?)) " +
+			"AND (`switch_id` IS NULL OR (`switch_id`, IFNULL(`run_id`, 0)) NOT IN (SELECT switch_id, run_id FROM (SELECT switch_id, MAX(run_id) AS run_id FROM `" + table + "` " +
+			"WHERE run_id IS NOT NULL AND switch_id IS NOT NULL GROUP BY switch_id) latest)) " +
+			"LIMIT ?"
+		for {
+			deleted, err := Execute_query_context(ctx, s.DB, query, lastRun, cutoff, policy.BatchSize)
+			if err != nil {
+				return result, fmt.Errorf("error pruning %s: %w", table, err)
+			}
+			result.Deleted[table] += deleted
+			if deleted < int64(policy.BatchSize) {
+				break
+			}
+		}
+		if result.Deleted[table] > 0 {
+			log.Printf("Prune :: %s :: %d rows older than %d days deleted", table, result.Deleted[table], days)
+		}
+	}
+
+	for table, column := range retention_history_tables {
+		days := policy.Days(table)
+		if days <= 0 {
+			continue
+		}
+		query := "DELETE FROM `" + table + "` WHERE `" + column + "` < ? LIMIT ?"
+		for {
+			deleted, err := Execute_query_context(ctx, s.DB, query, now.AddDate(0, 0, -days), policy.BatchSize)
+			if err != nil {
+				return result, fmt.Errorf("error pruning %s: %w", table, err)
+			}
+			result.Deleted[table] += deleted
+			if deleted < int64(policy.BatchSize) {
+				break
+			}
+		}
+		if result.Deleted[table] > 0 {
+			log.Printf("Prune :: %s :: %d rows older than %d days deleted", table, result.Deleted[table], days)
+		}
+	}
+
+	// Runs older than DefaultDays that no table refers to anymore are removed as well.
+	if policy.DefaultDays > 0 {
+		var unreferenced []string
+		for _, table := range retention_tables {
+			unreferenced = append(unreferenced, "NOT EXISTS (SELECT 1 FROM `"+table+"` WHERE `"+table+"`.`run_id` = `collection_runs`.`id`)")
+		}
+		runs, err := Execute_query_context(ctx, s.DB, "DELETE FROM `collection_runs` WHERE `finished_at` IS NOT NULL AND `started_at` < ? AND "+strings.Join(unreferenced, " AND "), now.AddDate(0, 0, -policy.DefaultDays))
+		if err != nil {
+			return result, fmt.Errorf("error pruning collection runs: %w", err)
+		}
+		result.Runs = runs
+	}
+	result.Duration = time.Since(start)
+
+	log.Printf("Prune :: %d collection runs deleted in %s", result.Runs, result.Duration.Round(time.Millisecond))
+	return result, nil
+}
+
+// Prune runs s.Prune against Default_store.
+func Prune(ctx context.Context, policy RetentionPolicy) (PruneResult, error) {
+	s, err := Default_store()
+	if err != nil {
+		return PruneResult{}, err
+	}
+	return s.Prune(ctx, policy)
+}
@@ -0,0 +1,161 @@
+package cisco_database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Error classes returned by Classify_error.
+const (
+	ErrorAuth    = "auth"
+	ErrorTimeout = "timeout"
+	ErrorRefused = "refused"
+	ErrorDNS     = "dns"
+	ErrorParse   = "parse"
+	ErrorDB      = "db"
+	ErrorOther   = "other"
+)
+
+// RetryPolicy controls how a failed device command is retried.
+// The wait before attempt n+1 is InitialBackoff * Multiplier^(n-1), capped at MaxBackoff,
+// randomly shortened or lengthened by up to Jitter (0.2 = 20%).
+type RetryPolicy struct {
+	// Attempts is the total number of tries of a command. 1 disables retries.
+	Attempts       int           `yaml:"attempts" toml:"attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" toml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" toml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier" toml:"multiplier"`
+	Jitter         float64       `yaml:"jitter" toml:"jitter"`
+	// RetryOn lists the error classes retried. Default timeout, refused and other;
+	// auth and parse failures would only fail again.
+	RetryOn []string `yaml:"retry_on" toml:"retry_on"`
+}
+
+// Default_retry_policy tries a command 3 times, waiting about 2s then 4s.
+func Default_retry_policy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:       3,
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryOn:        []string{ErrorTimeout, ErrorRefused, ErrorOther},
+	}
+}
+
+// Retryable reports whether errors of the class are retried.
+func (policy RetryPolicy) Retryable(class string) bool {
+	return slices.Contains(policy.RetryOn, class)
+}
+
+// Backoff returns the wait after the given failed attempt, starting at 1.
+func (policy RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := float64(policy.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= max(policy.Multiplier, 1)
+	}
+	if policy.MaxBackoff > 0 {
+		delay = min(delay, float64(policy.MaxBackoff))
+	}
+	if policy.Jitter > 0 {
+		delay *= 1 + policy.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+// retry_policy returns the Store policy, Default_retry_policy when it is not set.
+func (s *Store) retry_policy() RetryPolicy {
+	if s.Retry.Attempts == 0 {
+		return Default_retry_policy()
+	}
+	return s.Retry
+}
+
+// CollectError is the error of a device command, with its class and the number of attempts made.
+type CollectError struct {
+	Class    string
+	Attempts int
+	Err      error
+}
+
+func (e *CollectError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%v (%s error, %d attempts)", e.Err, e.Class, e.Attempts)
+	}
+	return e.Err.Error()
+}
+
+func (e *CollectError) Unwrap() error {
+	return e.Err
+}
+
+// Classify_error returns the class of an error returned by a collection step.
+func Classify_error(err error) string {
+	var collectErr *CollectError
+	if errors.As(err, &collectErr) {
+		return collectErr.Class
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.Is(err, sql.ErrTxDone) {
+		return ErrorDB
+	}
+
+	switch Reachability_state(err) {
+	case ReachAuthFailed:
+		return ErrorAuth
+	case ReachTimeout:
+		return ErrorTimeout
+	case ReachRefused:
+		return ErrorRefused
+	case ReachDNSFailed:
+		return ErrorDNS
+	}
+
+	// The cisco parsers fail with "error parsing ...", "could not parse ..." or "could not find ... header".
+	message := strings.ToLower(err.Error())
+	if strings.Contains(message, "pars") || strings.Contains(message, "could not find") || strings.Contains(message, "no interface configurations found") {
+		return ErrorParse
+	}
+	return ErrorOther
+}
+
+// retry_command runs a device command with run_context, retrying the failures the policy allows.
+// The returned error is a *CollectError. The final outcome is recorded for Run_collectors, see device_login.
+func retry_command[T any](ctx context.Context, policy RetryPolicy, switch_hostname string, command string, fn func() (T, error)) (T, error) {
+	attempts := max(policy.Attempts, 1)
+	for attempt := 1; ; attempt++ {
+		value, err := run_context(ctx, fn)
+		if err == nil {
+			record_device_login(ctx, switch_hostname, nil)
+			return value, nil
+		}
+		if ctx.Err() != nil {
+			return value, err
+		}
+
+		class := Classify_error(err)
+		if attempt >= attempts || !policy.Retryable(class) {
+			err = &CollectError{Class: class, Attempts: attempt, Err: err}
+			record_device_login(ctx, switch_hostname, err)
+			return value, err
+		}
+
+		delay := policy.Backoff(attempt)
+		log.Printf("%s :: %s :: %s error, attempt %d of %d, retrying in %s :: %v", switch_hostname, command, class, attempt, attempts, delay.Round(time.Millisecond), err)
+		if err := sleep_context(ctx, delay); err != nil {
+			return value, err
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package cisco_database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReverseDNSConfig controls the PTR lookups of Update_fqdn_table.
+type ReverseDNSConfig struct {
+	// Resolver is the DNS server queried, "10.0.0.53" or "127.0.0.1:5353". Empty uses the system resolver.
+	Resolver string `yaml:"resolver" toml:"resolver"`
+	// Concurrency is the number of lookups in flight. Default 16.
+	Concurrency int `yaml:"concurrency" toml:"concurrency"`
+	// CacheTTL is how long an answer, or the lack of one, is reused. Default 1h, negative disables the cache.
+	CacheTTL time.Duration `yaml:"cache_ttl" toml:"cache_ttl"`
+	// Timeout bounds one lookup. Default 2s.
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// ReverseDNS resolves IP addresses to names with a cache. It is safe for concurrent use.
+type ReverseDNS struct {
+	resolver    *net.Resolver
+	concurrency int
+	ttl         time.Duration
+	timeout     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]ptr_cache_entry
+}
+
+type ptr_cache_entry struct {
+	fqdn    string
+	expires time.Time
+}
+
+// New_reverse_dns returns a ReverseDNS querying cfg.Resolver, with the defaults for the unset fields.
+func New_reverse_dns(cfg ReverseDNSConfig) *ReverseDNS {
+	r := &ReverseDNS{
+		resolver:    net.DefaultResolver,
+		concurrency: cfg.Concurrency,
+		ttl:         cfg.CacheTTL,
+		timeout:     cfg.Timeout,
+		cache:       make(map[string]ptr_cache_entry),
+	}
+	if r.concurrency <= 0 {
+		r.concurrency = 16
+	}
+	if r.ttl == 0 {
+		r.ttl = time.Hour
+	}
+	if r.timeout <= 0 {
+		r.timeout = 2 * time.Second
+	}
+
+	if cfg.Resolver != "" {
+		address := cfg.Resolver
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, "53")
+		}
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, address)
+			},
+		}
+	}
+	return r
+}
+
+// Lookup returns the first PTR name of ip without its trailing dot, "" when it has none.
+func (r *ReverseDNS) Lookup(ctx context.Context, ip string) (string, error) {
+	now := time.Now()
+	r.mu.Lock()
+	entry, ok := r.cache[ip]
+	r.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.fqdn, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	fqdn := ""
+	names, err := r.resolver.LookupAddr(ctx, ip)
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil && len(names) > 0:
+		fqdn = strings.TrimSuffix(names[0], ".")
+	case err == nil, errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		// No PTR record, cached like an answer.
+	default:
+		return "", err
+	}
+
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.cache[ip] = ptr_cache_entry{fqdn: fqdn, expires: now.Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return fqdn, nil
+}
+
+// Lookup_all resolves the addresses with r.concurrency lookups in flight.
+// Addresses whose lookup failed are missing from the names and listed in the errors.
+func (r *ReverseDNS) Lookup_all(ctx context.Context, ips []string) (map[string]string, map[string]error) {
+	names := make(map[string]string)
+	failed := make(map[string]error)
+	var mu sync.Mutex
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range min(r.concurrency, max(len(ips), 1)) {
+		wg.Go(func() {
+			for ip := range jobs {
+				fqdn, err := r.Lookup(ctx, ip)
+				mu.Lock()
+				if err != nil {
+					failed[ip] = err
+				} else {
+					names[ip] = fqdn
+				}
+				mu.Unlock()
+			}
+		})
+	}
+
+feed:
+	for _, ip := range ips {
+		select {
+		case jobs <- ip:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return names, failed
+}
+
+// reverse_dns returns the Store ReverseDNS, a shared one using the system resolver when none is set.
+func (s *Store) reverse_dns() *ReverseDNS {
+	if s.ReverseDNS == nil {
+		return default_reverse_dns
+	}
+	return s.ReverseDNS
+}
+
+var default_reverse_dns = New_reverse_dns(ReverseDNSConfig{})
+
+// FqdnResult reports what Update_fqdn_table resolved.
+type FqdnResult struct {
+	// IPs is the number of distinct addresses looked up.
+	IPs      int
+	Resolved int
+	NotFound int
+	Failed   int
+	// Rows is the number of fqdn_table rows inserted or changed.
+	Rows     int64
+	Duration time.Duration
+}
+
+// Update_fqdn_table looks up the PTR name of every distinct IP of the latest ARP tables and
+// upserts one fqdn_table row per MAC and IP. An address without a PTR record gets a NULL fqdn,
+// a failed lookup leaves its rows as they were.
+func (s *Store) Update_fqdn_table(ctx context.Context) (FqdnResult, error) {
+	start := time.Now()
+	var result FqdnResult
+
+	rows, err := Return_query_context(ctx, s.DB, "SELECT DISTINCT arp.mac_address, arp.ip_address FROM "+latest_arp)
+	if err != nil {
+		return result, fmt.Errorf("error reading arp_table: %w", err)
+	}
+
+	type pair struct{ mac, ip string }
+	var pairs []pair
+	var ips []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		p := pair{mac: Row_string(row, "mac_address"), ip: Row_string(row, "ip_address")}
+		pairs = append(pairs, p)
+		if !seen[p.ip] {
+			seen[p.ip] = true
+			ips = append(ips, p.ip)
+		}
+	}
+	result.IPs = len(ips)
+
+	names, failed := s.reverse_dns().Lookup_all(ctx, ips)
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	for _, fqdn := range names {
+		if fqdn == "" {
+			result.NotFound++
+		} else {
+			result.Resolved++
+		}
+	}
+	result.Failed = len(failed)
+	for ip, err := range failed {
+		log.Printf("Reverse DNS :: %s :: %v", ip, err)
+	}
+
+	now := time.Now().UTC()
+	const batchSize = 1000
+	sqlStr := "INSERT INTO `fqdn_table` (`mac_address`, `ip_address`, `fqdn`, `resolved_at`) VALUES "
+	placeholderRow := "(?, ?, ?, ?)"
+	upsert := " ON DUPLICATE KEY UPDATE `fqdn` = VALUES(`fqdn`), `resolved_at` = VALUES(`resolved_at`)"
+
+	var valueStrings []string
+	var valueArgs []any
+	flush := func() error {
+		if len(valueStrings) == 0 {
+			return nil
+		}
+		affected, err := Execute_query_context(ctx, s.DB, sqlStr+strings.Join(valueStrings, ",")+upsert, valueArgs...)
+		if err != nil {
+			return fmt.Errorf("error upserting fqdn_table: %w", err)
+		}
+		result.Rows += affected
+		valueStrings, valueArgs = valueStrings[:0], valueArgs[:0]
+		return nil
+	}
+
+	for _, p := range pairs {
+		fqdn, ok := names[p.ip]
+		if !ok {
+			continue
+		}
+		var value any
+		if fqdn != "" {
+			value = fqdn
+		}
+		valueStrings = append(valueStrings, placeholderRow)
+		valueArgs = append(valueArgs, p.mac, p.ip, value, now)
+		if len(valueStrings) == batchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+
+	result.Duration = time.Since(start)
+	log.Printf("Reverse DNS :: %d addresses, %d resolved, %d without PTR, %d failed in %s", result.IPs, result.Resolved, result.NotFound, result.Failed, result.Duration.Round(time.Millisecond))
+	return result, nil
+}
+
+// Update_fqdn_table runs s.Update_fqdn_table against Default_store.
+func Update_fqdn_table(ctx context.Context) (FqdnResult, error) {
+	s, err := Default_store()
+	if err != nil {
+		return FqdnResult{}, err
+	}
+	return s.Update_fqdn_table(ctx)
+}
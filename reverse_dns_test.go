@@ -0,0 +1,196 @@
+package cisco_database
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dns_stub is an in-process UDP DNS server answering PTR queries from a fixed table.
+// Names missing from the table get NXDOMAIN, names in silent are never answered.
+type dns_stub struct {
+	conn   net.PacketConn
+	ptr    map[string]string
+	silent map[string]bool
+
+	mu      sync.Mutex
+	queries map[string]int
+}
+
+func start_dns_stub(t *testing.T, ptr map[string]string, silent ...string) *dns_stub {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &dns_stub{conn: conn, ptr: ptr, silent: make(map[string]bool), queries: make(map[string]int)}
+	for _, name := range silent {
+		stub.silent[name] = true
+	}
+	t.Cleanup(func() { conn.Close() })
+	go stub.serve()
+	return stub
+}
+
+func (d *dns_stub) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := d.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+			continue
+		}
+		question := query.Questions[0]
+		name := question.Name.String()
+
+		d.mu.Lock()
+		d.queries[name]++
+		d.mu.Unlock()
+		if d.silent[name] {
+			continue
+		}
+
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true, RecursionDesired: query.RecursionDesired, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		target, ok := d.ptr[name]
+		switch {
+		case !ok:
+			reply.RCode = dnsmessage.RCodeNameError
+		case question.Type == dnsmessage.TypePTR:
+			reply.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: 300},
+				Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(target)},
+			}}
+		}
+		packed, err := reply.Pack()
+		if err != nil {
+			continue
+		}
+		d.conn.WriteTo(packed, addr)
+	}
+}
+
+func (d *dns_stub) count(name string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.queries[name]
+}
+
+// The addresses are in TEST-NET-1 so that no hosts file entry answers them before the stub.
+const (
+	stub_ip_ptr      = "192.0.2.10"
+	stub_ip_nxdomain = "192.0.2.20"
+	stub_ip_silent   = "192.0.2.30"
+
+	stub_name_ptr      = "10.2.0.192.in-addr.arpa."
+	stub_name_nxdomain = "20.2.0.192.in-addr.arpa."
+	stub_name_silent   = "30.2.0.192.in-addr.arpa."
+)
+
+func new_stub_reverse_dns(t *testing.T, ttl time.Duration) (*ReverseDNS, *dns_stub) {
+	stub := start_dns_stub(t, map[string]string{stub_name_ptr: "sw1.example.com."}, stub_name_silent)
+	r := New_reverse_dns(ReverseDNSConfig{
+		Resolver: stub.conn.LocalAddr().String(),
+		CacheTTL: ttl,
+		Timeout:  200 * time.Millisecond,
+	})
+	return r, stub
+}
+
+func TestReverseDNSLookup(t *testing.T) {
+	r, stub := new_stub_reverse_dns(t, time.Hour)
+	ctx := context.Background()
+
+	fqdn, err := r.Lookup(ctx, stub_ip_ptr)
+	if err != nil || fqdn != "sw1.example.com" {
+		t.Errorf("Lookup(%s) = %q, %v, want sw1.example.com", stub_ip_ptr, fqdn, err)
+	}
+
+	fqdn, err = r.Lookup(ctx, stub_ip_nxdomain)
+	if err != nil || fqdn != "" {
+		t.Errorf("Lookup(%s) = %q, %v, want no name and no error", stub_ip_nxdomain, fqdn, err)
+	}
+
+	if _, err := r.Lookup(ctx, stub_ip_silent); err == nil {
+		t.Errorf("Lookup(%s) did not fail on an unanswered query", stub_ip_silent)
+	}
+
+	// Answers and NXDOMAIN are cached, the timeout is not.
+	ptr, nxdomain, silent := stub.count(stub_name_ptr), stub.count(stub_name_nxdomain), stub.count(stub_name_silent)
+	r.Lookup(ctx, stub_ip_ptr)
+	r.Lookup(ctx, stub_ip_nxdomain)
+	r.Lookup(ctx, stub_ip_silent)
+	if got := stub.count(stub_name_ptr); got != ptr {
+		t.Errorf("cached PTR queried again: %d queries, want %d", got, ptr)
+	}
+	if got := stub.count(stub_name_nxdomain); got != nxdomain {
+		t.Errorf("cached NXDOMAIN queried again: %d queries, want %d", got, nxdomain)
+	}
+	if got := stub.count(stub_name_silent); got == silent {
+		t.Errorf("failed lookup was cached: %d queries", got)
+	}
+}
+
+func TestReverseDNSCacheTTL(t *testing.T) {
+	r, stub := new_stub_reverse_dns(t, 50*time.Millisecond)
+	ctx := context.Background()
+
+	for _, ip := range []string{stub_ip_ptr, stub_ip_nxdomain} {
+		if _, err := r.Lookup(ctx, ip); err != nil {
+			t.Fatalf("Lookup(%s): %v", ip, err)
+		}
+	}
+	ptr, nxdomain := stub.count(stub_name_ptr), stub.count(stub_name_nxdomain)
+
+	time.Sleep(100 * time.Millisecond)
+	fqdn, err := r.Lookup(ctx, stub_ip_ptr)
+	if err != nil || fqdn != "sw1.example.com" {
+		t.Errorf("Lookup(%s) after expiry = %q, %v", stub_ip_ptr, fqdn, err)
+	}
+	r.Lookup(ctx, stub_ip_nxdomain)
+	if stub.count(stub_name_ptr) == ptr {
+		t.Errorf("expired PTR answer was reused")
+	}
+	if stub.count(stub_name_nxdomain) == nxdomain {
+		t.Errorf("expired NXDOMAIN answer was reused")
+	}
+}
+
+func TestReverseDNSCacheDisabled(t *testing.T) {
+	r, stub := new_stub_reverse_dns(t, -1)
+	ctx := context.Background()
+
+	r.Lookup(ctx, stub_ip_nxdomain)
+	queries := stub.count(stub_name_nxdomain)
+	r.Lookup(ctx, stub_ip_nxdomain)
+	if stub.count(stub_name_nxdomain) == queries {
+		t.Errorf("negative CacheTTL still cached the answer")
+	}
+}
+
+func TestReverseDNSLookupAll(t *testing.T) {
+	r, _ := new_stub_reverse_dns(t, time.Hour)
+
+	names, failed := r.Lookup_all(context.Background(), []string{stub_ip_ptr, stub_ip_nxdomain, stub_ip_silent})
+	if got := names[stub_ip_ptr]; got != "sw1.example.com" {
+		t.Errorf("names[%s] = %q, want sw1.example.com", stub_ip_ptr, got)
+	}
+	if got, ok := names[stub_ip_nxdomain]; !ok || got != "" {
+		t.Errorf("names[%s] = %q, %v, want an empty name", stub_ip_nxdomain, got, ok)
+	}
+	if _, ok := names[stub_ip_silent]; ok {
+		t.Errorf("unanswered %s listed in names", stub_ip_silent)
+	}
+	if len(failed) != 1 || failed[stub_ip_silent] == nil {
+		t.Errorf("failed = %v, want only %s", failed, stub_ip_silent)
+	}
+}
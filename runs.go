@@ -0,0 +1,184 @@
+package cisco_database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Collection run statuses stored in collection_runs.status.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunPartial   = "partial"
+	RunFailed    = "failed"
+)
+
+// Run is one row of collection_runs. Every row written by a collector carries the id
+// of the run it belongs to, so a run is a consistent snapshot of the switches it covered.
+type Run struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt time.Time // zero while the run is still running
+	Trigger    string
+	Status     string
+}
+
+type run_key struct{}
+
+// With_run returns a context whose collectors store their rows under run_id.
+func With_run(ctx context.Context, run_id int64) context.Context {
+	return context.WithValue(ctx, run_key{}, run_id)
+}
+
+// Run_from_context returns the run id set with With_run, or 0 when there is none.
+func Run_from_context(ctx context.Context) int64 {
+	run_id, _ := ctx.Value(run_key{}).(int64)
+	return run_id
+}
+
+// run_arg returns the run id of ctx as a query argument, NULL when there is none.
+func run_arg(ctx context.Context) any {
+	if run_id := Run_from_context(ctx); run_id > 0 {
+		return run_id
+	}
+	return nil
+}
+
+// Start_run inserts a running collection_runs row and returns its id.
+// trigger records what started the run, e.g. "collect_all" or "process_switch".
+func (s *Store) Start_run(ctx context.Context, trigger string) (int64, error) {
+	// Timestamps are written in UTC so server timezone changes do not move runs around.
+	result, err := s.DB.ExecContext(ctx, "INSERT INTO `collection_runs` (`started_at`, `trigger`, `status`) VALUES (?, ?, ?)", time.Now().UTC(), trigger, RunRunning)
+	if err != nil {
+		return 0, fmt.Errorf("error starting collection run: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// Finish_run records the end time and final status of a run.
+func (s *Store) Finish_run(ctx context.Context, run_id int64, status string) error {
+	// The run is finished even when ctx was cancelled, that is when it matters most.
+	_, err := Execute_query_context(context.WithoutCancel(ctx), s.DB, "UPDATE `collection_runs` SET `finished_at` = ?, `status` = ? WHERE `id` = ?", time.Now().UTC(), status, run_id)
+	if err != nil {
+		err = fmt.Errorf("error finishing collection run %d: %w", run_id, err)
+	}
+
+	// The configs written during the run go into a single backup commit.
+	if s.Exporter != nil {
+		if _, commitErr := s.Exporter.Commit(run_id); commitErr != nil {
+			err = errors.Join(err, fmt.Errorf("error backing up collection run %d: %w", run_id, commitErr))
+		}
+	}
+	return err
+}
+
+// Run_by_id returns one collection run.
+func (s *Store) Run_by_id(ctx context.Context, run_id int64) (Run, error) {
+	var run Run
+	var finished sql.NullTime
+	err := s.DB.QueryRowContext(ctx, "SELECT `id`, `started_at`, `finished_at`, `trigger`, `status` FROM `collection_runs` WHERE `id` = ?", run_id).
+		Scan(&run.ID, &run.StartedAt, &finished, &run.Trigger, &run.Status)
+	if err != nil {
+		return Run{}, fmt.Errorf("error reading collection run %d: %w", run_id, err)
+	}
+	run.FinishedAt = finished.Time
+	return run, nil
+}
+
+// Latest_run_id returns the most recent run that stored interfaces for the switch,
+// or 0 when it has none.
+func (s *Store) Latest_run_id(ctx context.Context, switch_id int64) (int64, error) {
+	var run_id sql.NullInt64
+	err := s.DB.QueryRowContext(ctx, "SELECT MAX(`run_id`) FROM `interfaces` WHERE `switch_id` = ?", switch_id).Scan(&run_id)
+	if err != nil {
+		return 0, fmt.Errorf("error reading latest run of switch %d: %w", switch_id, err)
+	}
+	return run_id.Int64, nil
+}
+
+// ensure_run returns ctx unchanged when it already carries a run. Otherwise it starts a run
+// with the given trigger and returns a context carrying it; finish records its status.
+func (s *Store) ensure_run(ctx context.Context, trigger string) (context.Context, func(status string), error) {
+	if Run_from_context(ctx) > 0 {
+		return ctx, func(string) {}, nil
+	}
+
+	run_id, err := s.Start_run(ctx, trigger)
+	if err != nil {
+		return ctx, nil, err
+	}
+	finish := func(status string) {
+		if err := s.Finish_run(ctx, run_id, status); err != nil {
+			log.Print(err)
+		}
+	}
+	return With_run(ctx, run_id), finish, nil
+}
+
+// in_run runs collect inside the run carried by ctx, or inside a new run started with trigger.
+func (s *Store) in_run(ctx context.Context, trigger string, collect func(ctx context.Context) (int64, error)) error {
+	ctx, finish, err := s.ensure_run(ctx, trigger)
+	if err != nil {
+		return err
+	}
+	_, err = collect(ctx)
+	finish(run_status(err))
+	return err
+}
+
+// record_step stores the outcome of a step of the switch in collection_steps; a re-run step replaces it.
+func (s *Store) record_step(ctx context.Context, run_id, switch_id int64, step StepResult) error {
+	if run_id == 0 {
+		return nil
+	}
+	var message any
+	if step.Err != nil {
+		message = step.Err.Error()
+	}
+	_, err := Execute_query_context(context.WithoutCancel(ctx), s.DB, "INSERT INTO `collection_steps` (`run_id`, `switch_id`, `step`, `status`, `rows`, `error_class`, `error`, `duration_ms`) VALUES (?, ?, ?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE `status` = VALUES(`status`), `rows` = VALUES(`rows`), `error_class` = VALUES(`error_class`), `error` = VALUES(`error`), `duration_ms` = VALUES(`duration_ms`)",
+		run_id, switch_id, step.Name, string(step.Status), step.Rows, null_string(step.Class), message, step.Duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("error recording step %s of run %d: %w", step.Name, run_id, err)
+	}
+	return nil
+}
+
+// run_status maps the error of a single collection to a run status.
+func run_status(err error) string {
+	if err != nil {
+		return RunFailed
+	}
+	return RunSucceeded
+}
+
+// Start_run runs s.Start_run against Default_store.
+func Start_run(ctx context.Context, trigger string) (int64, error) {
+	s, err := Default_store()
+	if err != nil {
+		return 0, err
+	}
+	return s.Start_run(ctx, trigger)
+}
+
+// Finish_run runs s.Finish_run against Default_store.
+func Finish_run(ctx context.Context, run_id int64, status string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Finish_run(ctx, run_id, status)
+}
+
+// Latest_run_id runs s.Latest_run_id against Default_store.
+func Latest_run_id(ctx context.Context, switch_id int64) (int64, error) {
+	s, err := Default_store()
+	if err != nil {
+		return 0, err
+	}
+	return s.Latest_run_id(ctx, switch_id)
+}
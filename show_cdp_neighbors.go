@@ -1,6 +1,7 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -18,36 +19,49 @@ type CdpNeighbor struct {
 	NeighborInterface string
 }
 
-func Show_cdp_neighbors(switch_id int64, switch_hostname string) error {
-	show_cdp_neighbors_data, err := cisco.Show_cdp_neighbors(switch_hostname)
+func (s *Store) Show_cdp_neighbors(switch_id int64, switch_hostname string) error {
+	return s.Show_cdp_neighbors_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_cdp_neighbors_context is Show_cdp_neighbors with a context that cancels the collection and its database writes.
+func (s *Store) Show_cdp_neighbors_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_cdp_neighbors", func(ctx context.Context) (int64, error) {
+		return s.show_cdp_neighbors(ctx, switch_id, switch_hostname)
+	})
+}
+
+// show_cdp_neighbors collects the command output and returns the number of rows stored.
+func (s *Store) show_cdp_neighbors(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	var show_cdp_neighbors_data []cisco.CdpNeighbor
+	var err error
+	if in_session(ctx, switch_hostname) {
+		show_cdp_neighbors_data, err = s.session_show_cdp_neighbors(ctx, switch_hostname)
+	} else {
+		show_cdp_neighbors_data, err = retry_command(ctx, s.retry_policy(), switch_hostname, "show cdp neighbors", func() ([]cisco.CdpNeighbor, error) {
+			return cisco.Show_cdp_neighbors(switch_hostname)
+		})
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Check the length of the slice, not the map.
 	if len(show_cdp_neighbors_data) == 0 {
 		log.Printf("Warning: Parsing completed for %s, but no cdp_neighbors were found.", switch_hostname)
-		return nil
-	}
-
-	// Establish the database connection.
-	db, err := DB_connect()
-	if err != nil {
-		log.Print(err)
+		return 0, nil
 	}
-	defer db.Close()
 
-	// Delete records
-	deleteQuery := fmt.Sprintf("DELETE FROM cdp_neighbors WHERE switch_id = %d AND DATE(created_at) = CURDATE()", switch_id)
-	Execute_query(db, deleteQuery)
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM cdp_neighbors WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
 
-	sqlStr := "INSERT INTO `cdp_neighbors` (`switch_id`, `interface`, `neighbor_name`, `neighbor_interface`, `capabilities`, `platform`) VALUES "
+	sqlStr := "INSERT INTO `cdp_neighbors` (`switch_id`, `run_id`, `interface`, `neighbor_name`, `neighbor_interface`, `capabilities`, `platform`) VALUES "
 	// 2. Create slices for the placeholder strings and the actual values
 	var valueStrings []string
 	var valueArgs []any // Use []any (or []interface{})
 
 	// This is the placeholder group for a SINGLE row (25 placeholders)
-	placeholderRow := "(?, ?, ?, ?, ?, ?)"
+	placeholderRow := "(?, ?, ?, ?, ?, ?, ?)"
 
 	// Iterate over the slice, which maintains the correct order.
 	for _, details := range show_cdp_neighbors_data {
@@ -56,6 +70,7 @@ func Show_cdp_neighbors(switch_id int64, switch_hostname string) error {
 		// Add the values for this row, in the *exact* same order as the columns above
 		valueArgs = append(valueArgs,
 			switch_id, // The switch_id from the function argument
+			run_arg(ctx),
 			details.Interface,
 			details.Neighbor,
 			details.NeighborInterface,
@@ -64,30 +79,212 @@ func Show_cdp_neighbors(switch_id int64, switch_hostname string) error {
 		)
 	}
 	finalQuery := sqlStr + strings.Join(valueStrings, ",")
-	tx, err := db.Begin()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
-	// Use tx.Exec() with the final query and the flat slice of all values
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	// Use tx.ExecContext() with the final query and the flat slice of all values
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		// Roll back the transaction if there's an error
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s: %v", switch_hostname, err)
 		log.Printf("Failed query: %s", finalQuery) // Log the query for debugging
-		return err
+		return 0, err
 	}
 
 	// If successful, commit the transaction
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
 	log.Printf("%d :: %s :: Show CDP Neighbors :: %d records inserted.\n", switch_id, switch_hostname, len(show_cdp_neighbors_data))
 
-	return nil
+	return int64(len(show_cdp_neighbors_data)), nil
+}
+
+// Show_cdp_neighbors runs s.Show_cdp_neighbors against Default_store.
+func Show_cdp_neighbors(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_cdp_neighbors(switch_id, switch_hostname)
+}
+
+// Show_cdp_neighbors_context runs s.Show_cdp_neighbors_context against Default_store.
+func Show_cdp_neighbors_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_cdp_neighbors_context(ctx, switch_id, switch_hostname)
+}
+
+// session_show_cdp_neighbors runs "show cdp neighbors" over the session of ctx and parses it like cisco.Show_cdp_neighbors.
+// A switch with CDP disabled has no neighbor table, which is logged and stores nothing.
+func (s *Store) session_show_cdp_neighbors(ctx context.Context, switch_hostname string) ([]cisco.CdpNeighbor, error) {
+	outputString, err := s.run_command(ctx, switch_hostname, "show cdp neighbors")
+	if err != nil {
+		return nil, err
+	}
+
+	show_cdp_neighbors_data, err := parseCdpNeighbors(outputString)
+	if err != nil {
+		log.Printf("%s ::Show CDP Neighbors :: Error during parsing: %v", switch_hostname, err)
+	}
+	for i := range show_cdp_neighbors_data {
+		show_cdp_neighbors_data[i].Interface = normalize_interface_name(show_cdp_neighbors_data[i].Interface)
+		show_cdp_neighbors_data[i].NeighborInterface = normalize_interface_name(show_cdp_neighbors_data[i].NeighborInterface)
+	}
+	return show_cdp_neighbors_data, nil
+}
+
+// parseCdpNeighbors processes the raw CLI output from "show cdp neighbors" and converts it into a list of cisco.CdpNeighbor structs.
+// It finds the column positions from the header and handles entries that span two lines.
+func parseCdpNeighbors(rawOutput string) ([]cisco.CdpNeighbor, error) {
+	var neighbors []cisco.CdpNeighbor
+	lines := strings.Split(rawOutput, "\n")
+
+	headerLine := ""
+	headerIndex := -1
+
+	// 1. Find the header line
+	for i, line := range lines {
+		if strings.Contains(line, "Device") && strings.Contains(line, "Port ID") {
+			headerLine = line
+			headerIndex = i
+			break
+		}
+	}
+
+	if headerIndex == -1 {
+		log.Println("CDP neighbors header not found, returning empty list.")
+		return neighbors, nil
+	}
+
+	// Start parsing from the line IMMEDIATELY following the header
+	dataStartIndex := headerIndex + 1
+
+	// 2. Determine the start index of each column from the header.
+	localIntfIndex := strings.Index(headerLine, "Local Intrfce")
+	holdtmeIndex := strings.Index(headerLine, "Hldtme")
+	if holdtmeIndex == -1 {
+		holdtmeIndex = strings.Index(headerLine, "Holdtme")
+	}
+	capabilityIndex := strings.Index(headerLine, "Capability")
+	platformIndex := strings.Index(headerLine, "Platform")
+	portIDIndex := strings.Index(headerLine, "Port ID")
+
+	if localIntfIndex == -1 || holdtmeIndex == -1 || capabilityIndex == -1 || platformIndex == -1 || portIDIndex == -1 {
+		return nil, fmt.Errorf("could not parse CDP neighbors header columns correctly (check alignment)")
+	}
+
+	var lastDeviceID string
+
+	for i := dataStartIndex; i < len(lines); i++ {
+		line := lines[i]
+		trimmedLine := strings.TrimSpace(line)
+
+		// Explicitly skip non-data lines.
+		if trimmedLine == "" || strings.Contains(trimmedLine, "Total cdp entries") || strings.Contains(trimmedLine, "Device-ID") || strings.Contains(trimmedLine, "---") {
+			continue
+		}
+
+		// LOGIC: Distinguish between 3 types of lines:
+		// A. Detail Line: Starts with whitespace (Device ID column is empty).
+		// B. Single-Line Entry: Starts with text AND is long enough to contain a Platform.
+		// C. Device ID Only: Starts with text BUT is too short to be a full entry.
+
+		// Check 1: Is it a Detail Line? (Indented)
+		// We check if the line is long enough to have interface data, but the Device ID area is empty.
+		isDetailLine := false
+		if len(line) > localIntfIndex {
+			deviceIDArea := strings.TrimSpace(line[0:localIntfIndex])
+			if deviceIDArea == "" && strings.TrimSpace(line[localIntfIndex:]) != "" {
+				isDetailLine = true
+			}
+		}
+
+		if isDetailLine {
+			// *** TYPE A: DETAIL LINE (Second line of a split entry) ***
+			if lastDeviceID == "" {
+				log.Printf("Warning: Found detail line without preceding Device ID: %s", line)
+				continue
+			}
+
+			// Bounds check for safety
+			if len(line) < portIDIndex {
+				// Try to salvage what we can, or skip if critical data is missing
+				if len(line) < platformIndex {
+					log.Printf("Warning: Detail line too short to parse: %s", line)
+					continue
+				}
+			}
+
+			// For the detail line, we extract assuming the standard column headers apply
+			neighbor := cisco.CdpNeighbor{
+				Neighbor:          lastDeviceID,
+				Interface:         cdp_column(line, localIntfIndex, holdtmeIndex),
+				HoldTime:          cdp_column(line, holdtmeIndex, capabilityIndex),
+				Capability:        cdp_column(line, capabilityIndex, platformIndex),
+				Platform:          cdp_column(line, platformIndex, portIDIndex),
+				NeighborInterface: cdp_column(line, portIDIndex, len(line)),
+			}
+			neighbors = append(neighbors, neighbor)
+			lastDeviceID = ""
+
+		} else {
+			// It starts with text. It is either Type B (Single Line) or Type C (Device ID Only).
+
+			// *** CRITICAL FIX: Use platformIndex as the threshold ***
+			// If a line is shorter than the start of the Platform column, it CANNOT be a full entry.
+			// This correctly identifies "debb015-a.hub.nd.edu" as a "Device ID Only" line,
+			// even if it spills into the Local Intrfce column area.
+
+			if len(line) >= platformIndex {
+				// *** TYPE B: SINGLE-LINE ENTRY ***
+
+				// Extract the Device ID safely
+				deviceID := ""
+				if len(line) > localIntfIndex {
+					deviceID = strings.TrimSpace(line[0:localIntfIndex])
+				} else {
+					deviceID = trimmedLine
+				}
+
+				neighbor := cisco.CdpNeighbor{
+					Neighbor:          deviceID,
+					Interface:         cdp_column(line, localIntfIndex, holdtmeIndex),
+					HoldTime:          cdp_column(line, holdtmeIndex, capabilityIndex),
+					Capability:        cdp_column(line, capabilityIndex, platformIndex),
+					Platform:          cdp_column(line, platformIndex, portIDIndex),
+					NeighborInterface: cdp_column(line, portIDIndex, len(line)),
+				}
+				neighbors = append(neighbors, neighbor)
+				lastDeviceID = ""
+
+			} else {
+				// *** TYPE C: DEVICE ID ONLY LINE ***
+				// The line is too short to be a full record. It is just a Device ID.
+				lastDeviceID = trimmedLine
+			}
+		}
+	}
+
+	return neighbors, nil
+}
+
+// cdp_column returns the trimmed text of line between two header positions,
+// short lines (e.g. a Port ID cut by the terminal) give the part they have.
+func cdp_column(line string, start, end int) string {
+	end = min(end, len(line))
+	if start >= end {
+		return ""
+	}
+	return strings.TrimSpace(line[start:end])
 }
@@ -1,60 +1,77 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
 
 	"github.com/xtokio/cisco"
 )
 
 // Show_interfaces connects to a switch, gets interface data, and returns it as a map.
-func Show_interfaces(switch_id int64, switch_hostname string) error {
-	show_interface_data, err := cisco.Show_interfaces(switch_hostname)
+func (s *Store) Show_interfaces(switch_id int64, switch_hostname string) error {
+	return s.Show_interfaces_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_interfaces_context is Show_interfaces with a context that cancels the collection and its database writes.
+func (s *Store) Show_interfaces_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_interfaces", func(ctx context.Context) (int64, error) {
+		return s.show_interfaces(ctx, switch_id, switch_hostname)
+	})
+}
+
+// show_interfaces collects the command output and returns the number of rows stored.
+func (s *Store) show_interfaces(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	var show_interface_data []cisco.InterfaceDetails
+	var err error
+	if in_session(ctx, switch_hostname) {
+		show_interface_data, err = s.session_show_interfaces(ctx, switch_hostname)
+	} else {
+		show_interface_data, err = retry_command(ctx, s.retry_policy(), switch_hostname, "show interfaces", func() ([]cisco.InterfaceDetails, error) {
+			return cisco.Show_interfaces(switch_hostname)
+		})
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Check the length of the slice, not the map.
 	if len(show_interface_data) == 0 {
 		log.Printf("Show Interfaces ::Warning: Parsing completed for %s, but no interfaces were found.", switch_hostname)
-		return nil
+		return 0, nil
 	}
 
-	process_show_interfaces(show_interface_data, switch_id, switch_hostname)
-
-	// Return the data
-	return nil
+	// Return the number of rows stored
+	return s.process_show_interfaces(ctx, show_interface_data, switch_id, switch_hostname)
 }
 
-func process_show_interfaces(interfacesSlice []cisco.InterfaceDetails, switch_id int64, switch_hostname string) {
-	// Establish the database connection.
-	db, err := DB_connect()
-	if err != nil {
-		log.Print(err)
-	}
-	defer db.Close()
+func (s *Store) process_show_interfaces(ctx context.Context, interfacesSlice []cisco.InterfaceDetails, switch_id int64, switch_hostname string) (int64, error) {
 
-	// Delete records from today
-	deleteQuery := fmt.Sprintf("DELETE FROM interfaces WHERE switch_id = %d AND DATE(created_at) = CURDATE()", switch_id)
-	Execute_query(db, deleteQuery)
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM interfaces WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
 
 	sqlStr := "INSERT INTO `interfaces` (" +
-		"`switch_id`, `interface`, `description`, `ip_address`, `link_status`, `protocol_status`, `hardware_type`, `reliability`, `txload`, `rxload`, `mtu`, `duplex`, `speed`, `media_type`, `bandwidth`, `delay`, `encapsulation`, `last_input`, `last_output`, `last_output_hang`, `queue_strategy`, `input_rate`, `output_rate`, `input_packets`, `output_packets`, `runts`, `giants`, `throttles`, `input_errors`, `output_errors`, `crc_errors`, `collisions`) VALUES "
+		"`switch_id`, `run_id`, `interface`, `description`, `ip_address`, `link_status`, `protocol_status`, `hardware_type`, `reliability`, `txload`, `rxload`, `mtu`, `duplex`, `speed`, `media_type`, `bandwidth`, `delay`, `encapsulation`, `last_input`, `last_output`, `last_output_hang`, `queue_strategy`, `input_rate`, `output_rate`, `input_packets`, `output_packets`, `runts`, `giants`, `throttles`, `input_errors`, `output_errors`, `crc_errors`, `collisions`, " +
+		"`mtu_bytes`, `bandwidth_kbit`, `delay_usec`, `reliability_fraction`, `txload_fraction`, `rxload_fraction`, `input_rate_bps`, `output_rate_bps`, `input_packets_total`, `output_packets_total`, `input_bytes_total`, `output_bytes_total`, `runts_total`, `giants_total`, `throttles_total`, `input_errors_total`, `output_errors_total`, `crc_errors_total`, `collisions_total`) VALUES "
 	// 2. Create slices for the placeholder strings and the actual values
 	var valueStrings []string
 	var valueArgs []any // Use []any (or []interface{})
 
-	// This is the placeholder group for a SINGLE row (25 placeholders)
-	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	// This is the placeholder group for a SINGLE row (33 raw + 19 typed placeholders)
+	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 
 	// Iterate over the slice, which maintains the correct order.
 	for _, details := range interfacesSlice {
 		// Add the placeholder group for this row
 		valueStrings = append(valueStrings, placeholderRow)
+		counters := Parse_interface_counters(details)
 		// Add the values for this row, in the *exact* same order as the columns above
 		valueArgs = append(valueArgs,
 			switch_id, // The switch_id from the function argument
+			run_arg(ctx),
 			details.Interface,
 			details.Description,
 			details.IPAddress,
@@ -86,33 +103,309 @@ func process_show_interfaces(interfacesSlice []cisco.InterfaceDetails, switch_id
 			details.OutputErrors,
 			details.CrcErrors,
 			details.Collisions,
+			counters.MtuBytes,
+			counters.BandwidthKbit,
+			counters.DelayUsec,
+			counters.Reliability,
+			counters.TxLoad,
+			counters.RxLoad,
+			counters.InputRateBps,
+			counters.OutputRateBps,
+			counters.InputPackets,
+			counters.OutputPackets,
+			counters.InputBytes,
+			counters.OutputBytes,
+			counters.Runts,
+			counters.Giants,
+			counters.Throttles,
+			counters.InputErrors,
+			counters.OutputErrors,
+			counters.CrcErrors,
+			counters.Collisions,
 		)
 	}
 	finalQuery := sqlStr + strings.Join(valueStrings, ",")
 
-	tx, err := db.Begin()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
-		return
+		return 0, err
 	}
 
-	// Use tx.Exec() with the final query and the flat slice of all values
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	// Use tx.ExecContext() with the final query and the flat slice of all values
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		// Roll back the transaction if there's an error
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s: %v", switch_hostname, err)
 		log.Printf("Failed query: %s", finalQuery) // Log the query for debugging
-		return
+		return 0, err
 	}
 
 	// If successful, commit the transaction
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
-		return
+		return 0, err
 	}
 
 	log.Printf("%d :: %s :: Show Interfaces :: %d records inserted.\n", switch_id, switch_hostname, len(interfacesSlice))
 
+	return int64(len(interfacesSlice)), nil
+}
+
+// Show_interfaces runs s.Show_interfaces against Default_store.
+func Show_interfaces(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_interfaces(switch_id, switch_hostname)
+}
+
+// Show_interfaces_context runs s.Show_interfaces_context against Default_store.
+func Show_interfaces_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_interfaces_context(ctx, switch_id, switch_hostname)
+}
+
+// session_show_interfaces runs "show interface" over the session of ctx and parses it like cisco.Show_interfaces.
+func (s *Store) session_show_interfaces(ctx context.Context, switch_hostname string) ([]cisco.InterfaceDetails, error) {
+	outputString, err := s.run_command(ctx, switch_hostname, "show interface")
+	if err != nil {
+		return nil, err
+	}
+
+	show_interface_data, err := parseInterfaces(outputString)
+	if err != nil {
+		log.Printf("Error during parsing 'show interfaces' output for %s: %v", switch_hostname, err)
+		return nil, &CollectError{Class: ErrorParse, Attempts: 1, Err: fmt.Errorf("error during parsing 'show interfaces' output for %s: %v", switch_hostname, err)}
+	}
+	for i := range show_interface_data {
+		show_interface_data[i].Interface = normalize_interface_name(show_interface_data[i].Interface)
+	}
+	return show_interface_data, nil
+}
+
+func findString(re *regexp.Regexp, s string) string {
+	matches := re.FindStringSubmatch(s)
+	if len(matches) > 1 {
+		return strings.TrimSpace(matches[1])
+	}
+	return ""
+}
+
+// parseInterfaces is updated with a highly specific reInterfaceStart regex.
+func parseInterfaces(rawOutput string) ([]cisco.InterfaceDetails, error) {
+	var interfaces []cisco.InterfaceDetails
+	var currentBlock []string
+
+	// THIS IS THE CRITICAL CHANGE:
+	// We now require the first word to contain at least one digit.
+	// This matches "GigabitEthernet1/0/13" and "Ethernet101/1/23"
+	// but will NOT match "admin state is up...".
+	reInterfaceStart := regexp.MustCompile(`^(\S+\d+\S*)\s+is\s+.*`)
+
+	// --- Cleaning Logic ---
+	var cleanLines []string
+	parsingActive := false
+	rePrompt := regexp.MustCompile(`^\S+[>#]\s*$`)
+
+	lines := strings.Split(rawOutput, "\n")
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if !parsingActive && strings.Contains(line, "show interface") {
+			parsingActive = true
+			continue
+		}
+		if parsingActive && rePrompt.MatchString(line) {
+			parsingActive = false
+		}
+		if parsingActive {
+			cleanLines = append(cleanLines, line)
+		}
+	}
+
+	for _, line := range cleanLines {
+		if reInterfaceStart.MatchString(line) {
+			if len(currentBlock) > 0 {
+				iface := parseSingleInterface(strings.Join(currentBlock, "\n"))
+				if iface.Interface != "" {
+					interfaces = append(interfaces, iface)
+				}
+			}
+			currentBlock = []string{line}
+		} else if len(currentBlock) > 0 {
+			// This line is part of the previous block
+			currentBlock = append(currentBlock, line)
+		}
+	}
+
+	// Process the last block
+	if len(currentBlock) > 0 {
+		iface := parseSingleInterface(strings.Join(currentBlock, "\n"))
+		if iface.Interface != "" {
+			interfaces = append(interfaces, iface)
+		}
+	}
+
+	return interfaces, nil
+}
+
+// parseSingleInterface is updated to handle both IOS and Nexus-style output.
+func parseSingleInterface(block string) cisco.InterfaceDetails {
+	iface := cisco.InterfaceDetails{}
+
+	// --- Regex definitions updated for flexibility ---
+
+	// Status: Made "line protocol" optional to handle both "is up, line protocol is up" (IOS)
+	// and just "is up" (Nexus)
+	reStatus := regexp.MustCompile(`^(\S+)\s+is\s+(administratively down|down|up|err-disabled|deleted)(?:,\s+line\s+protocol\s+is\s+(down|up|down \(disabled\)))?`)
+
+	// Hardware: Allows "Hardware is" (IOS) or "Hardware:" (Nexus)
+	reHardware := regexp.MustCompile(`Hardware(?::| is) ([^,]+), address is ([\w\.]+)`)
+
+	reDescription := regexp.MustCompile(`Description:\s*(.*)`)
+	reAddress := regexp.MustCompile(`Internet address is ([\d\.]+\/\d+)`)
+
+	// Mtu/Bw/Dly: Made "/sec" and trailing comma optional
+	reMtuBwDly := regexp.MustCompile(`MTU (\d+) bytes, BW (\d+) Kbit(?:/sec)?, DLY (\d+) usec(?:,)?`)
+
+	// Duplex/Speed/Media: Made "media type" optional (present in IOS, absent in Nexus)
+	reDuplexSpeedMedia := regexp.MustCompile(`\s*(\S+-duplex),\s*([^,]+)(?:,\s*media type is (.*))?`)
+
+	// Encapsulation: Made trailing comma optional
+	reEncapsulation := regexp.MustCompile(`\s*Encapsulation ([^,]+),?`)
+
+	reReliabilityLoad := regexp.MustCompile(`reliability\s+(\d+\/\d+),\s+txload\s+(\d+\/\d+),\s+rxload\s+(\d+\/\d+)`)
+
+	// Rates: Looks for "5 minute" (IOS) or "30 seconds" (Nexus) which both use "bits/sec"
+	reRates := regexp.MustCompile(`(?s)(?:5 minute|30 seconds) input rate (\d+) bits/sec,.*(?:5 minute|30 seconds) output rate (\d+) bits/sec`)
+
+	// Counters: Allows "packets input" (IOS) or "input packets" (Nexus) and an optional comma
+	reInputCounters := regexp.MustCompile(`(\d+)\s+(?:packets\s+input|input\s+packets)(?:,)?\s+(\d+)\s+bytes`)
+
+	// --- Split Input/CRC Errors for Nexus ---
+	reInputErrors := regexp.MustCompile(`(\d+)\s+input\s+errors,\s+(\d+)\s+CRC`) // IOS
+	reInputErrorsNexus := regexp.MustCompile(`(\d+)\s+input\s+error(?:s)?`)      // Nexus Input Errors
+	reCrcErrorsNexus := regexp.MustCompile(`(\d+)\s+CRC`)                        // Nexus CRC (found elsewhere in block)
+
+	// Counters: Allows "packets output" (IOS) or "output packets" (Nexus) and an optional comma
+	reOutputCounters := regexp.MustCompile(`(\d+)\s+(?:packets\s+output|output\s+packets)(?:,)?\s+(\d+)\s+bytes`)
+
+	// Output Errors: Allows optional comma and "collision" or "collisions"
+	reOutputErrors := regexp.MustCompile(`(\d+)\s+output\s+errors(?:,)?\s+(\d+)\s+collision(?:s)?`)
+
+	reLastIO := regexp.MustCompile(`\s*Last input\s+(.*?),` + `\s+output\s+(.*?),` + `\s+output hang\s+(.*)`)
+	reQueueStrategy := regexp.MustCompile(`Queueing strategy:\s*(.*)`)
+
+	// --- Split Runts/Giants/Throttles for Nexus ---
+	reRuntsGiantsThrottles := regexp.MustCompile(`\s*(\d+)\s+runts,\s+(\d+)\s+giants,\s+(\d+)\s+throttles`) // IOS
+	reRuntsGiantsNexus := regexp.MustCompile(`\s*(\d+)\s+runts\s+(\d+)\s+giants`)                           // Nexus (no throttles here, and no commas)
+
+	// --- Logic to assign values ---
+
+	if matches := reStatus.FindStringSubmatch(block); len(matches) > 2 {
+		iface.Interface = matches[1]
+		iface.LinkStatus = matches[2]
+		// Check if the optional 3rd capture group (protocol status) was captured
+		if len(matches) > 3 && matches[3] != "" {
+			iface.ProtocolStatus = matches[3] // IOS: "up" or "down"
+		} else {
+			// If not (e.g., Nexus output), set protocol status to be the same as link status
+			iface.ProtocolStatus = matches[2]
+		}
+	} else {
+		// This log should no longer be hit by "admin state"
+		log.Printf("Failed to parse block with reStatus regex. Block content:\n---\n%s\n---", block)
+		return cisco.InterfaceDetails{}
+	}
+
+	if matches := reHardware.FindStringSubmatch(block); len(matches) > 2 {
+		iface.Hardware = strings.TrimSpace(matches[1])
+		iface.MacAddress = strings.TrimSpace(matches[2])
+	}
+
+	iface.Description = strings.TrimSpace(findString(reDescription, block))
+	iface.IPAddress = findString(reAddress, block)
+
+	if matches := reMtuBwDly.FindStringSubmatch(block); len(matches) > 3 {
+		iface.Mtu = matches[1]
+		iface.Bandwidth = matches[2]
+		iface.Delay = matches[3]
+	}
+
+	if matches := reDuplexSpeedMedia.FindStringSubmatch(block); len(matches) > 2 {
+		iface.Duplex = strings.TrimSpace(matches[1])
+		iface.Speed = strings.TrimSpace(matches[2])
+		// Check if optional "media type" (group 3) was captured
+		if len(matches) > 3 && matches[3] != "" {
+			iface.MediaType = strings.TrimSpace(matches[3])
+		}
+	}
+
+	iface.Encapsulation = findString(reEncapsulation, block)
+
+	if matches := reReliabilityLoad.FindStringSubmatch(block); len(matches) > 3 {
+		iface.Reliability = matches[1]
+		iface.TxLoad = matches[2]
+		iface.RxLoad = matches[3]
+	}
+
+	if matches := reRates.FindStringSubmatch(block); len(matches) > 2 {
+		iface.InputRateBps = matches[1]
+		iface.OutputRateBps = matches[2]
+	}
+
+	if matches := reInputCounters.FindStringSubmatch(block); len(matches) > 2 {
+		iface.PacketsInput = matches[1]
+		iface.BytesInput = matches[2]
+	}
+
+	// Use conditional logic for errors, as formats differ significantly
+	if matches := reInputErrors.FindStringSubmatch(block); len(matches) > 2 {
+		// IOS style
+		iface.InputErrors = matches[1]
+		iface.CrcErrors = matches[2]
+	} else {
+		// Try Nexus style (errors and CRC are on different lines)
+		iface.InputErrors = findString(reInputErrorsNexus, block)
+		iface.CrcErrors = findString(reCrcErrorsNexus, block) // findString will get the CRC value
+	}
+
+	if matches := reOutputCounters.FindStringSubmatch(block); len(matches) > 2 {
+		iface.PacketsOutput = matches[1]
+		iface.BytesOutput = matches[2]
+	}
+
+	if matches := reOutputErrors.FindStringSubmatch(block); len(matches) > 2 {
+		iface.OutputErrors = matches[1]
+		iface.Collisions = matches[2]
+	}
+
+	if matches := reLastIO.FindStringSubmatch(block); len(matches) > 3 {
+		iface.LastInput = strings.TrimSpace(matches[1])
+		iface.LastOutput = strings.TrimSpace(matches[2])
+		iface.OutputHang = strings.TrimSpace(matches[3])
+	}
+
+	iface.QueueStrategy = findString(reQueueStrategy, block)
+
+	// Use conditional logic for runts/giants, as formats differ
+	if matches := reRuntsGiantsThrottles.FindStringSubmatch(block); len(matches) > 3 {
+		// IOS style
+		iface.Runts = matches[1]
+		iface.Giants = matches[2]
+		iface.Throttles = matches[3]
+	} else if matches := reRuntsGiantsNexus.FindStringSubmatch(block); len(matches) > 2 {
+		// Try Nexus style (no throttles on this line)
+		iface.Runts = matches[1]
+		iface.Giants = matches[2]
+		// Throttles will remain empty, which is correct
+	}
+
+	return iface
 }
@@ -1,6 +1,7 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -19,39 +20,52 @@ type InterfaceStatus struct {
 	Type        string
 }
 
-func Show_interfaces_status(switch_id int64, switch_hostname string) error {
-	show_interface_status_data, err := cisco.Show_interfaces_status(switch_hostname)
+func (s *Store) Show_interfaces_status(switch_id int64, switch_hostname string) error {
+	return s.Show_interfaces_status_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_interfaces_status_context is Show_interfaces_status with a context that cancels the collection and its database writes.
+func (s *Store) Show_interfaces_status_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_interfaces_status", func(ctx context.Context) (int64, error) {
+		return s.show_interfaces_status(ctx, switch_id, switch_hostname)
+	})
+}
+
+// show_interfaces_status collects the command output and returns the number of rows stored.
+func (s *Store) show_interfaces_status(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	var show_interface_status_data []cisco.InterfaceStatus
+	var err error
+	if in_session(ctx, switch_hostname) {
+		show_interface_status_data, err = s.session_show_interfaces_status(ctx, switch_hostname)
+	} else {
+		show_interface_status_data, err = retry_command(ctx, s.retry_policy(), switch_hostname, "show interfaces status", func() ([]cisco.InterfaceStatus, error) {
+			return cisco.Show_interfaces_status(switch_hostname)
+		})
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Check the length of the slice, not the map.
 	if len(show_interface_status_data) == 0 {
 		log.Printf("Show Interface Status :: Warning: Parsing completed for %s, but no interfaces were found.", switch_hostname)
-		return nil
-	}
-
-	// Establish the database connection.
-	db, err := DB_connect()
-	if err != nil {
-		log.Print(err)
+		return 0, nil
 	}
-	defer db.Close()
 
-	// Delete records
-	deleteQuery := fmt.Sprintf("DELETE FROM interfaces_status WHERE switch_id = %d", switch_id)
-	Execute_query(db, deleteQuery)
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM interfaces_status WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
 
 	// 2. Define the update query.
 	// NOTE: We MUST include 'interface_name' in the WHERE clause to update
 	// each interface with its specific Vlan.
-	insertQuery := "INSERT INTO `interfaces_status` (`switch_id`, `interface`, `description`, `status`, `vlan_id`, `duplex`, `speed`, `type`) VALUES "
+	insertQuery := "INSERT INTO `interfaces_status` (`switch_id`, `run_id`, `interface`, `description`, `status`, `vlan_id`, `duplex`, `speed`, `type`) VALUES "
 	// 2. Create slices for the placeholder strings and the actual values
 	var valueStrings []string
 	var valueArgs []any // Use []any (or []interface{})
 
 	// This is the placeholder group for a SINGLE row (25 placeholders)
-	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?)"
+	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
 
 	// Iterate over the slice, which maintains the correct order.
 	for _, details := range show_interface_status_data {
@@ -60,6 +74,7 @@ func Show_interfaces_status(switch_id int64, switch_hostname string) error {
 		// Add the values for this row, in the *exact* same order as the columns above
 		valueArgs = append(valueArgs,
 			switch_id, // The switch_id from the function argument
+			run_arg(ctx),
 			details.Interface,
 			details.Description,
 			details.Status,
@@ -72,30 +87,144 @@ func Show_interfaces_status(switch_id int64, switch_hostname string) error {
 
 	finalQuery := insertQuery + strings.Join(valueStrings, ",")
 
-	tx, err := db.Begin()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
-	// Use tx.Exec() with the final query and the flat slice of all values
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	// Use tx.ExecContext() with the final query and the flat slice of all values
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		// Roll back the transaction if there's an error
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s: %v", switch_hostname, err)
 		log.Printf("Failed query: %s", finalQuery) // Log the query for debugging
-		return err
+		return 0, err
 	}
 
 	// If successful, commit the transaction
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
 	log.Printf("%d :: %s :: Show Interface Status :: %d records updated.\n", switch_id, switch_hostname, len(show_interface_status_data))
 
-	return nil
+	return int64(len(show_interface_status_data)), nil
+}
+
+// Show_interfaces_status runs s.Show_interfaces_status against Default_store.
+func Show_interfaces_status(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_interfaces_status(switch_id, switch_hostname)
+}
+
+// Show_interfaces_status_context runs s.Show_interfaces_status_context against Default_store.
+func Show_interfaces_status_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_interfaces_status_context(ctx, switch_id, switch_hostname)
+}
+
+// session_show_interfaces_status runs "show interface status" over the session of ctx and parses it like cisco.Show_interfaces_status.
+func (s *Store) session_show_interfaces_status(ctx context.Context, switch_hostname string) ([]cisco.InterfaceStatus, error) {
+	outputString, err := s.run_command(ctx, switch_hostname, "show interface status")
+	if err != nil {
+		return nil, err
+	}
+
+	show_interface_status_data, err := parseInterfaceStatus(outputString)
+	if err != nil {
+		log.Printf("%s :: Show Interface Status ::Error during parsing: %v", switch_hostname, err)
+		return nil, &CollectError{Class: ErrorParse, Attempts: 1, Err: err}
+	}
+	return show_interface_status_data, nil
+}
+
+// parseInterfaceStatus processes the raw CLI output and converts it into a list of cisco.InterfaceStatus structs.
+// It locates the 'Status' field first, which correctly handles variable-length
+// Description and Type fields.
+func parseInterfaceStatus(rawOutput string) ([]cisco.InterfaceStatus, error) {
+	var interfaces []cisco.InterfaceStatus
+	lines := strings.Split(rawOutput, "\n")
+
+	dataStartIndex := -1
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.Contains(line, "Port") && strings.Contains(line, "Vlan") {
+			dataStartIndex = i + 1
+			break
+		}
+	}
+
+	if dataStartIndex == -1 || dataStartIndex >= len(lines) {
+		return nil, fmt.Errorf("could not find interface status header in output")
+	}
+
+	for i := dataStartIndex; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if line == "" || strings.HasPrefix(line, "----") || strings.HasPrefix(line, "Name") {
+			continue // Skip blank lines, separators, or secondary headers
+		}
+
+		fields := strings.Fields(line)
+
+		// A line must have at least 6 fields:
+		// Port, Status, Vlan, Duplex, Speed, Type (Type can be multi-word)
+		if len(fields) < 6 {
+			// log.Printf("Show interface status :: Skipping line with insufficient field count (%d) :: %s", len(fields), line)
+			continue
+		}
+
+		status := cisco.InterfaceStatus{}
+		status.Interface = fields[0]
+
+		// Find the Status field. It's the first field after the Interface
+		// that is a known status keyword. We must leave at least 4 fields
+		// after it (Vlan, Duplex, Speed, Type).
+		statusIndex := -1
+
+		// We search from index 1 (after Port) up to len(fields) - 5
+		// (to leave room for Status, Vlan, Duplex, Speed, and at least one word for Type)
+		maxSearchIndex := len(fields) - 5
+		for j := 1; j <= maxSearchIndex; j++ {
+			s := fields[j]
+			// Add all known status types here
+			if s == "connected" || s == "notconnect" || s == "disabled" || s == "err-disabled" || s == "suspended" || s == "monitoring" {
+				statusIndex = j
+				break
+			}
+		}
+
+		// If we didn't find a status, this line is malformed.
+		if statusIndex == -1 {
+			// log.Printf("Show interface status :: Skipping line: could not determine Status field :: %s", line)
+			continue
+		}
+
+		// Now, assign all fields based on the correctly found statusIndex
+
+		// Description is everything between Interface (fields[0]) and Status (fields[statusIndex])
+		status.Description = strings.Join(fields[1:statusIndex], " ")
+
+		status.Status = fields[statusIndex]
+		status.VlanID = fields[statusIndex+1]
+		status.Duplex = fields[statusIndex+2]
+		status.Speed = fields[statusIndex+3]
+
+		// Type is everything that remains
+		status.Type = strings.Join(fields[statusIndex+4:], " ")
+
+		interfaces = append(interfaces, status)
+	}
+
+	return interfaces, nil
 }
@@ -0,0 +1,232 @@
+package cisco_database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// InventoryEntry is one NAME/DESCR/PID/VID/SN block of "show inventory".
+type InventoryEntry struct {
+	Name        string
+	Description string
+	PID         string
+	VID         string
+	Serial      string
+}
+
+func (s *Store) Show_inventory(switch_id int64, switch_hostname string) error {
+	return s.Show_inventory_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_inventory_context is Show_inventory with a context that cancels the collection and its database writes.
+func (s *Store) Show_inventory_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_inventory", func(ctx context.Context) (int64, error) {
+		return s.show_inventory(ctx, switch_id, switch_hostname)
+	})
+}
+
+// show_inventory collects the command output and returns the number of rows stored.
+func (s *Store) show_inventory(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	outputString, err := s.run_command(ctx, switch_hostname, "show inventory")
+	if err != nil {
+		return 0, err
+	}
+
+	show_inventory_data, err := parseInventory(outputString)
+	if err != nil {
+		log.Printf("%s :: Show Inventory :: Error during parsing: %v", switch_hostname, err)
+		return 0, &CollectError{Class: ErrorParse, Attempts: 1, Err: err}
+	}
+
+	if len(show_inventory_data) == 0 {
+		log.Printf("Show Inventory :: Warning: Parsing completed for %s, but no entries were found.", switch_hostname)
+		return 0, nil
+	}
+
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM inventory WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
+
+	sqlStr := "INSERT INTO `inventory` (`switch_id`, `run_id`, `name`, `description`, `pid`, `vid`, `serial`) VALUES "
+	var valueStrings []string
+	var valueArgs []any
+	placeholderRow := "(?, ?, ?, ?, ?, ?, ?)"
+
+	for _, entry := range show_inventory_data {
+		valueStrings = append(valueStrings, placeholderRow)
+		valueArgs = append(valueArgs,
+			switch_id,
+			run_arg(ctx),
+			entry.Name,
+			entry.Description,
+			entry.PID,
+			entry.VID,
+			entry.Serial,
+		)
+	}
+	finalQuery := sqlStr + strings.Join(valueStrings, ",")
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to execute bulk insert for %s: %v", switch_hostname, err)
+		log.Printf("Failed query: %s", finalQuery)
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
+		return 0, err
+	}
+
+	log.Printf("%d :: %s :: Show Inventory :: %d records inserted.\n", switch_id, switch_hostname, len(show_inventory_data))
+
+	return int64(len(show_inventory_data)), nil
+}
+
+// latest_inventory is the inventory of the latest run of each switch, aliased inv.
+const latest_inventory = "(SELECT i.* FROM inventory i JOIN (SELECT switch_id, MAX(run_id) AS run_id FROM inventory GROUP BY switch_id) latest_inv " +
+	"ON latest_inv.switch_id = i.switch_id AND latest_inv.run_id = i.run_id) inv"
+
+// InventoryItem is an inventory entry of the latest run of a switch.
+type InventoryItem struct {
+	SwitchID int64
+	Fqdn     string
+	RunID    int64
+	InventoryEntry
+}
+
+// Find_serial returns the entries of the latest inventories whose serial number is serial, ignoring case.
+// A serial moved to another switch is only reported where it was last seen.
+func (s *Store) Find_serial(ctx context.Context, serial string) ([]InventoryItem, error) {
+	serial = strings.TrimSpace(serial)
+	if serial == "" {
+		return nil, fmt.Errorf("error: empty serial number")
+	}
+	rows, err := Return_query_context(ctx, s.DB, "SELECT inv.switch_id, switches.fqdn, inv.run_id, inv.name, inv.description, inv.pid, inv.vid, inv.serial FROM "+latest_inventory+
+		" JOIN switches ON switches.id = inv.switch_id WHERE inv.serial = ? ORDER BY switches.fqdn, inv.name", serial)
+	if err != nil {
+		return nil, fmt.Errorf("error reading inventory: %w", err)
+	}
+
+	var items []InventoryItem
+	for _, row := range rows {
+		items = append(items, InventoryItem{
+			SwitchID: Row_int64(row, "switch_id"),
+			Fqdn:     Row_string(row, "fqdn"),
+			RunID:    Row_int64(row, "run_id"),
+			InventoryEntry: InventoryEntry{
+				Name:        Row_string(row, "name"),
+				Description: Row_string(row, "description"),
+				PID:         Row_string(row, "pid"),
+				VID:         Row_string(row, "vid"),
+				Serial:      Row_string(row, "serial"),
+			},
+		})
+	}
+	return items, nil
+}
+
+// PidCount is the number of entries of a PID in the latest inventories.
+type PidCount struct {
+	PID      string
+	Count    int64
+	Switches int64
+}
+
+// Count_inventory_pids counts the entries of the latest inventories by PID, the most common first.
+// pid_like is a LIKE pattern restricting the PIDs, e.g. "SFP%" or "GLC-%"; empty counts every PID.
+func (s *Store) Count_inventory_pids(ctx context.Context, pid_like string) ([]PidCount, error) {
+	if pid_like == "" {
+		pid_like = "%"
+	}
+	rows, err := Return_query_context(ctx, s.DB, "SELECT inv.pid, COUNT(*) AS count, COUNT(DISTINCT inv.switch_id) AS switches FROM "+latest_inventory+
+		" WHERE inv.pid <> '' AND inv.pid LIKE ? GROUP BY inv.pid ORDER BY count DESC, inv.pid", pid_like)
+	if err != nil {
+		return nil, fmt.Errorf("error reading inventory: %w", err)
+	}
+
+	var counts []PidCount
+	for _, row := range rows {
+		counts = append(counts, PidCount{
+			PID:      Row_string(row, "pid"),
+			Count:    Row_int64(row, "count"),
+			Switches: Row_int64(row, "switches"),
+		})
+	}
+	return counts, nil
+}
+
+// Show_inventory runs s.Show_inventory against Default_store.
+func Show_inventory(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_inventory(switch_id, switch_hostname)
+}
+
+// Show_inventory_context runs s.Show_inventory_context against Default_store.
+func Show_inventory_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_inventory_context(ctx, switch_id, switch_hostname)
+}
+
+// Find_serial runs s.Find_serial against Default_store.
+func Find_serial(ctx context.Context, serial string) ([]InventoryItem, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Find_serial(ctx, serial)
+}
+
+// Count_inventory_pids runs s.Count_inventory_pids against Default_store.
+func Count_inventory_pids(ctx context.Context, pid_like string) ([]PidCount, error) {
+	s, err := Default_store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Count_inventory_pids(ctx, pid_like)
+}
+
+var (
+	reInventoryName = regexp.MustCompile(`^\s*NAME:\s*"?(.*?)"?\s*,\s*DESCR:\s*"?(.*?)"?\s*$`)
+	reInventoryPid  = regexp.MustCompile(`^\s*PID:\s*(.*?)\s*,\s*VID:\s*(.*?)\s*,\s*SN:\s*(.*?)\s*$`)
+)
+
+// parseInventory processes the raw CLI output from "show inventory" on IOS, IOS-XE and NX-OS.
+// Each entry is a NAME/DESCR line followed by its PID/VID/SN line; an empty PID, VID or SN stays empty.
+func parseInventory(rawOutput string) ([]InventoryEntry, error) {
+	if strings.Contains(rawOutput, "% Invalid") {
+		return nil, fmt.Errorf("show inventory is not supported: %s", strings.TrimSpace(rawOutput))
+	}
+
+	var entries []InventoryEntry
+	var current *InventoryEntry
+	for _, line := range strings.Split(rawOutput, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := reInventoryName.FindStringSubmatch(line); m != nil {
+			entries = append(entries, InventoryEntry{Name: m[1], Description: m[2]})
+			current = &entries[len(entries)-1]
+			continue
+		}
+		if m := reInventoryPid.FindStringSubmatch(line); m != nil && current != nil {
+			current.PID, current.VID, current.Serial = m[1], m[2], m[3]
+			current = nil
+		}
+	}
+	return entries, nil
+}
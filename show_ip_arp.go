@@ -0,0 +1,278 @@
+package cisco_database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ArpEntry defines the structure for a single entry of "show ip arp".
+type ArpEntry struct {
+	Vrf        string
+	Protocol   string
+	IPAddress  string
+	Age        string
+	MacAddress string
+	Type       string
+	Interface  string
+}
+
+// Default_gateway_roles are the switch roles Show_ip_arp collects from when Store.GatewayRoles is empty.
+var Default_gateway_roles = []string{"gateway"}
+
+// Show_ip_arp collects the ARP table of a gateway switch into arp_table, so Update_interfaces
+// can resolve the IP address of the endpoints behind every access port.
+func (s *Store) Show_ip_arp(switch_id int64, switch_hostname string) error {
+	return s.Show_ip_arp_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_ip_arp_context is Show_ip_arp with a context that cancels the collection and its database writes.
+func (s *Store) Show_ip_arp_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_ip_arp", func(ctx context.Context) (int64, error) {
+		return s.show_ip_arp(ctx, switch_id, switch_hostname)
+	})
+}
+
+// is_gateway reports whether the Show_ip_arp collector runs on the switch.
+func (s *Store) is_gateway(sw Switch) bool {
+	roles := s.GatewayRoles
+	if len(roles) == 0 {
+		roles = Default_gateway_roles
+	}
+	return slices.ContainsFunc(roles, func(role string) bool { return strings.EqualFold(role, sw.Role) })
+}
+
+// show_ip_arp_collector runs show_ip_arp on the gateway switches only.
+func (s *Store) show_ip_arp_collector(ctx context.Context, sw Switch) (int64, error) {
+	if !s.is_gateway(sw) {
+		return 0, nil
+	}
+	return s.show_ip_arp(ctx, sw.ID, sw.Fqdn)
+}
+
+// show_ip_arp collects the command output and returns the number of rows stored.
+// NX-OS lists every VRF with "show ip arp vrf all" and the VRF of an entry is the one of its interface;
+// IOS lists the global table with "show ip arp" and each VRF of the running-config on its own.
+func (s *Store) show_ip_arp(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	// The VRFs come from the latest archived running-config, without one every entry is in the default VRF.
+	var tree *ConfigNode
+	if version, err := s.Latest_config_version(ctx, switch_id); err == nil {
+		tree = version.Tree()
+	} else {
+		log.Printf("%d :: %s :: Show IP ARP :: no archived running-config, entries are stored in the default VRF", switch_id, switch_hostname)
+		tree = &ConfigNode{}
+	}
+	interfaceVrfs := interface_vrfs(tree)
+
+	var arp_data []ArpEntry
+	if s.is_nxos(ctx, switch_id) {
+		outputString, err := s.run_command(ctx, switch_hostname, "show ip arp vrf all")
+		if err != nil {
+			return 0, err
+		}
+		entries, err := parseIpArp(outputString, "default", interfaceVrfs)
+		if err != nil {
+			return 0, &CollectError{Class: ErrorParse, Attempts: 1, Err: fmt.Errorf("error parsing 'show ip arp vrf all' output for %s: %v", switch_hostname, err)}
+		}
+		arp_data = entries
+	} else {
+		commands := map[string]string{"default": "show ip arp"}
+		for _, vrf := range config_vrfs(tree) {
+			commands[vrf] = "show ip arp vrf " + vrf
+		}
+		for _, vrf := range sorted_keys(commands) {
+			outputString, err := s.run_command(ctx, switch_hostname, commands[vrf])
+			if err != nil {
+				return 0, err
+			}
+			entries, err := parseIpArp(outputString, vrf, nil)
+			if err != nil {
+				return 0, &CollectError{Class: ErrorParse, Attempts: 1, Err: fmt.Errorf("error parsing '%s' output for %s: %v", commands[vrf], switch_hostname, err)}
+			}
+			arp_data = append(arp_data, entries...)
+		}
+	}
+
+	if len(arp_data) == 0 {
+		log.Printf("Show IP ARP :: Warning: Parsing completed for %s, but no ARP entries were found.", switch_hostname)
+		return 0, nil
+	}
+
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM arp_table WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	const batchSize = 1000
+	sqlStr := "INSERT INTO `arp_table` (`switch_id`, `run_id`, `source`, `vrf`, `mac_address`, `ip_address`, `protocol`, `age`, `type`, `interface`) VALUES "
+	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	for i := 0; i < len(arp_data); i += batchSize {
+		batch := arp_data[i:min(i+batchSize, len(arp_data))]
+
+		var valueStrings []string
+		var valueArgs []any
+		for _, entry := range batch {
+			valueStrings = append(valueStrings, placeholderRow)
+			valueArgs = append(valueArgs,
+				switch_id,
+				run_arg(ctx),
+				switch_hostname,
+				entry.Vrf,
+				entry.MacAddress,
+				entry.IPAddress,
+				entry.Protocol,
+				entry.Age,
+				entry.Type,
+				entry.Interface,
+			)
+		}
+
+		finalQuery := sqlStr + strings.Join(valueStrings, ",")
+		if _, err := tx.ExecContext(ctx, finalQuery, valueArgs...); err != nil {
+			log.Printf("Failed to execute bulk insert batch for %s: %v", switch_hostname, err)
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
+		return 0, err
+	}
+
+	log.Printf("%d :: %s :: Show IP ARP :: %d records found.\n", switch_id, switch_hostname, len(arp_data))
+	return int64(len(arp_data)), nil
+}
+
+// is_nxos reports whether Show_version found NX-OS on the switch.
+func (s *Store) is_nxos(ctx context.Context, switch_id int64) bool {
+	rows, err := Return_query_context(ctx, s.DB, "SELECT `hardware`, `software_image`, `version` FROM `switches` WHERE `id` = ?", switch_id)
+	if err != nil || len(rows) == 0 {
+		return false
+	}
+	platform := strings.ToLower(Row_string(rows[0], "hardware") + " " + Row_string(rows[0], "software_image") + " " + Row_string(rows[0], "version"))
+	return strings.Contains(platform, "nexus") || strings.Contains(platform, "nxos")
+}
+
+// config_vrfs returns the VRFs defined in a running-config ("vrf definition" and "ip vrf"),
+// without the management VRF whose ARP entries are of no use to resolve endpoints.
+func config_vrfs(tree *ConfigNode) []string {
+	var vrfs []string
+	for _, path := range []string{"vrf definition", "ip vrf"} {
+		for _, node := range tree.Find(path) {
+			fields := strings.Fields(node.Line)
+			if len(fields) != len(strings.Fields(path))+1 {
+				continue
+			}
+			vrf := fields[len(fields)-1]
+			if !strings.EqualFold(vrf, "Mgmt-vrf") && !slices.Contains(vrfs, vrf) {
+				vrfs = append(vrfs, vrf)
+			}
+		}
+	}
+	return vrfs
+}
+
+// interface_vrfs maps the normalized interface names of a running-config to their VRF
+// ("vrf member" on NX-OS, "vrf forwarding" or "ip vrf forwarding" on IOS).
+func interface_vrfs(tree *ConfigNode) map[string]string {
+	vrfs := make(map[string]string)
+	for _, node := range tree.Find("interface *") {
+		name := strings.TrimSpace(strings.TrimPrefix(node.Line, "interface"))
+		for _, path := range []string{"vrf member", "vrf forwarding", "ip vrf forwarding"} {
+			if vrf, ok := node.Value(path); ok && vrf != "" {
+				vrfs[normalize_interface_name(name)] = vrf
+				break
+			}
+		}
+	}
+	return vrfs
+}
+
+func sorted_keys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// --- PARSING FUNCTION ---
+
+var (
+	// IOS: "Internet  10.1.10.25   12   0050.56a3.5678  ARPA   Vlan10", the interface may be missing.
+	reArpIos = regexp.MustCompile(`^(Internet)\s+(\d+\.\d+\.\d+\.\d+)\s+(\S+)\s+([0-9a-fA-F]{4}\.[0-9a-fA-F]{4}\.[0-9a-fA-F]{4})\s+(\S+)\s*(\S*)`)
+	// NX-OS: "10.1.10.25  00:05:31  0050.56a3.5678  Vlan10  +", flags after the interface.
+	reArpNxos = regexp.MustCompile(`^(\d+\.\d+\.\d+\.\d+)\s+(\S+)\s+([0-9a-fA-F]{4}\.[0-9a-fA-F]{4}\.[0-9a-fA-F]{4})\s+(\S+)\s*(.*)$`)
+)
+
+// parseIpArp processes the raw CLI output of "show ip arp" (IOS) or "show ip arp vrf all" (NX-OS).
+// Entries are in vrf, unless their interface is listed in interfaceVrfs. Incomplete entries have no MAC and are skipped.
+func parseIpArp(rawOutput string, vrf string, interfaceVrfs map[string]string) ([]ArpEntry, error) {
+	if strings.Contains(rawOutput, "% Invalid") || strings.Contains(rawOutput, "% Incomplete") {
+		return nil, fmt.Errorf("command rejected by the device")
+	}
+
+	var entries []ArpEntry
+	for _, line := range strings.Split(rawOutput, "\n") {
+		line = strings.TrimSpace(line)
+
+		var entry ArpEntry
+		if matches := reArpIos.FindStringSubmatch(line); matches != nil {
+			entry = ArpEntry{
+				Protocol:   matches[1],
+				IPAddress:  matches[2],
+				Age:        matches[3],
+				MacAddress: strings.ToLower(matches[4]),
+				Type:       matches[5],
+				Interface:  normalize_interface_name(matches[6]),
+			}
+		} else if matches := reArpNxos.FindStringSubmatch(line); matches != nil {
+			entry = ArpEntry{
+				Protocol:   "Internet",
+				IPAddress:  matches[1],
+				Age:        matches[2],
+				MacAddress: strings.ToLower(matches[3]),
+				Type:       strings.TrimSpace(matches[5]),
+				Interface:  normalize_interface_name(matches[4]),
+			}
+		} else {
+			continue
+		}
+
+		entry.Vrf = vrf
+		if interfaceVrf, ok := interfaceVrfs[entry.Interface]; ok {
+			entry.Vrf = interfaceVrf
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Show_ip_arp runs s.Show_ip_arp against Default_store.
+func Show_ip_arp(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_ip_arp(switch_id, switch_hostname)
+}
+
+// Show_ip_arp_context runs s.Show_ip_arp_context against Default_store.
+func Show_ip_arp_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_ip_arp_context(ctx, switch_id, switch_hostname)
+}
@@ -0,0 +1,103 @@
+package cisco_database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseIpArpIos(t *testing.T) {
+	raw := "gw1#show ip arp vrf USERS\r\n" +
+		"Protocol  Address          Age (min)  Hardware Addr   Type   Interface\r\n" +
+		"Internet  10.1.10.1               -   0050.56A3.0001  ARPA   Vlan10\r\n" +
+		"Internet  10.1.10.25             12   0050.56a3.5678  ARPA   Vlan10\r\n" +
+		"Internet  10.1.20.7               0   Incomplete      ARPA\r\n" +
+		"Internet  10.1.30.9              45   0050.56a3.9abc  ARPA   GigabitEthernet1/0/48\r\n" +
+		"Internet  10.1.40.2             155   0050.56a3.def0  ARPA\r\n" +
+		"gw1#"
+
+	entries, err := parseIpArp(raw, "USERS", nil)
+	if err != nil {
+		t.Fatalf("parseIpArp: %v", err)
+	}
+	want := []ArpEntry{
+		{Vrf: "USERS", Protocol: "Internet", IPAddress: "10.1.10.1", Age: "-", MacAddress: "0050.56a3.0001", Type: "ARPA", Interface: "Vlan10"},
+		{Vrf: "USERS", Protocol: "Internet", IPAddress: "10.1.10.25", Age: "12", MacAddress: "0050.56a3.5678", Type: "ARPA", Interface: "Vlan10"},
+		{Vrf: "USERS", Protocol: "Internet", IPAddress: "10.1.30.9", Age: "45", MacAddress: "0050.56a3.9abc", Type: "ARPA", Interface: "Gi1/0/48"},
+		{Vrf: "USERS", Protocol: "Internet", IPAddress: "10.1.40.2", Age: "155", MacAddress: "0050.56a3.def0", Type: "ARPA", Interface: ""},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("parseIpArp =\n%+v\nwant\n%+v", entries, want)
+	}
+}
+
+func TestParseIpArpNxos(t *testing.T) {
+	tree := Parse_config_tree("vrf context USERS\n" +
+		"vrf context management\n" +
+		"interface Vlan10\n" +
+		"  vrf member USERS\n" +
+		"interface Ethernet1/49\n" +
+		"  vrf member USERS\n" +
+		"interface Vlan20\n" +
+		"  ip address 10.1.20.1/24\n")
+	raw := "nx1# show ip arp vrf all\n" +
+		"\n" +
+		"Flags: * - Adjacencies learnt on non active FHRP router\n" +
+		"       + - Adjacencies synced via CFSoE\n" +
+		"\n" +
+		"IP ARP Table for all contexts\n" +
+		"Total number of entries: 4\n" +
+		"Address         Age       MAC Address     Interface       Flags\n" +
+		"10.1.10.25      00:05:31  0050.56a3.5678  Vlan10          +\n" +
+		"10.1.20.7       00:00:12  0050.56a3.1111  Vlan20\n" +
+		"10.255.0.2      00:12:00  0050.56A3.2222  Ethernet1/49    *\n" +
+		"10.1.20.8       00:00:03  INCOMPLETE      Vlan20\n" +
+		"nx1#"
+
+	entries, err := parseIpArp(raw, "default", interface_vrfs(tree))
+	if err != nil {
+		t.Fatalf("parseIpArp: %v", err)
+	}
+	want := []ArpEntry{
+		{Vrf: "USERS", Protocol: "Internet", IPAddress: "10.1.10.25", Age: "00:05:31", MacAddress: "0050.56a3.5678", Type: "+", Interface: "Vlan10"},
+		{Vrf: "default", Protocol: "Internet", IPAddress: "10.1.20.7", Age: "00:00:12", MacAddress: "0050.56a3.1111", Type: "", Interface: "Vlan20"},
+		{Vrf: "USERS", Protocol: "Internet", IPAddress: "10.255.0.2", Age: "00:12:00", MacAddress: "0050.56a3.2222", Type: "*", Interface: "Ethernet1/49"},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("parseIpArp =\n%+v\nwant\n%+v", entries, want)
+	}
+}
+
+func TestParseIpArpRejected(t *testing.T) {
+	raw := "gw1#show ip arp vrf all\n" +
+		"                    ^\n" +
+		"% Invalid input detected at '^' marker.\n" +
+		"gw1#"
+	if _, err := parseIpArp(raw, "default", nil); err == nil {
+		t.Errorf("parseIpArp accepted a rejected command")
+	}
+}
+
+func TestConfigVrfs(t *testing.T) {
+	tree := Parse_config_tree("vrf definition Mgmt-vrf\n" +
+		" address-family ipv4\n" +
+		"vrf definition USERS\n" +
+		" rd 65000:10\n" +
+		"ip vrf VOICE\n" +
+		" rd 65000:20\n" +
+		"interface GigabitEthernet0/0\n" +
+		" vrf forwarding Mgmt-vrf\n" +
+		"interface Vlan10\n" +
+		" vrf forwarding USERS\n" +
+		"interface Vlan20\n" +
+		" ip vrf forwarding VOICE\n")
+
+	if got, want := config_vrfs(tree), []string{"USERS", "VOICE"}; !slices.Equal(got, want) {
+		t.Errorf("config_vrfs = %q, want %q", got, want)
+	}
+	vrfs := interface_vrfs(tree)
+	for name, want := range map[string]string{"Gi0/0": "Mgmt-vrf", "Vlan10": "USERS", "Vlan20": "VOICE"} {
+		if vrfs[name] != want {
+			t.Errorf("interface_vrfs[%s] = %q, want %q", name, vrfs[name], want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -17,36 +18,49 @@ type LldpNeighbor struct {
 	Capability        string
 }
 
-func Show_lldp_neighbors(switch_id int64, switch_hostname string) error {
-	show_lldp_neighbors_data, err := cisco.Show_lldp_neighbors(switch_hostname)
+func (s *Store) Show_lldp_neighbors(switch_id int64, switch_hostname string) error {
+	return s.Show_lldp_neighbors_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_lldp_neighbors_context is Show_lldp_neighbors with a context that cancels the collection and its database writes.
+func (s *Store) Show_lldp_neighbors_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_lldp_neighbors", func(ctx context.Context) (int64, error) {
+		return s.show_lldp_neighbors(ctx, switch_id, switch_hostname)
+	})
+}
+
+// show_lldp_neighbors collects the command output and returns the number of rows stored.
+func (s *Store) show_lldp_neighbors(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	var show_lldp_neighbors_data []cisco.LldpNeighbor
+	var err error
+	if in_session(ctx, switch_hostname) {
+		show_lldp_neighbors_data, err = s.session_show_lldp_neighbors(ctx, switch_hostname)
+	} else {
+		show_lldp_neighbors_data, err = retry_command(ctx, s.retry_policy(), switch_hostname, "show lldp neighbors", func() ([]cisco.LldpNeighbor, error) {
+			return cisco.Show_lldp_neighbors(switch_hostname)
+		})
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Check the length of the slice, not the map.
 	if len(show_lldp_neighbors_data) == 0 {
 		log.Printf("Show LLDP Neighbors :: Warning: Parsing completed for %s, but no interfaces were found.", switch_hostname)
-		return nil
-	}
-
-	// Establish the database connection.
-	db, err := DB_connect()
-	if err != nil {
-		log.Print(err)
+		return 0, nil
 	}
-	defer db.Close()
 
-	// Delete records
-	deleteQuery := fmt.Sprintf("DELETE FROM lldp_neighbors WHERE switch_id = %d AND DATE(created_at) = CURDATE()", switch_id)
-	Execute_query(db, deleteQuery)
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM lldp_neighbors WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
 
-	sqlStr := "INSERT INTO `lldp_neighbors` (`switch_id`, `interface`, `neighbor_name`, `neighbor_interface`, `capabilities`) VALUES "
+	sqlStr := "INSERT INTO `lldp_neighbors` (`switch_id`, `run_id`, `interface`, `neighbor_name`, `neighbor_interface`, `capabilities`) VALUES "
 	// 2. Create slices for the placeholder strings and the actual values
 	var valueStrings []string
 	var valueArgs []any // Use []any (or []interface{})
 
 	// This is the placeholder group for a SINGLE row (25 placeholders)
-	placeholderRow := "(?, ?, ?, ?, ?)"
+	placeholderRow := "(?, ?, ?, ?, ?, ?)"
 
 	// Iterate over the slice, which maintains the correct order.
 	for _, details := range show_lldp_neighbors_data {
@@ -55,6 +69,7 @@ func Show_lldp_neighbors(switch_id int64, switch_hostname string) error {
 		// Add the values for this row, in the *exact* same order as the columns above
 		valueArgs = append(valueArgs,
 			switch_id, // The switch_id from the function argument
+			run_arg(ctx),
 			details.Interface,
 			details.Neighbor,
 			details.NeighborInterface,
@@ -62,31 +77,124 @@ func Show_lldp_neighbors(switch_id int64, switch_hostname string) error {
 		)
 	}
 	finalQuery := sqlStr + strings.Join(valueStrings, ",")
-	tx, err := db.Begin()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
-	// Use tx.Exec() with the final query and the flat slice of all values
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	// Use tx.ExecContext() with the final query and the flat slice of all values
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		// Roll back the transaction if there's an error
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s: %v", switch_hostname, err)
 		log.Printf("Failed query: %s", finalQuery) // Log the query for debugging
-		return err
+		return 0, err
 	}
 
 	// If successful, commit the transaction
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
 	log.Printf("%d :: %s :: Show LLDP Neighbors :: %d records inserted.\n", switch_id, switch_hostname, len(show_lldp_neighbors_data))
 
-	return nil
+	return int64(len(show_lldp_neighbors_data)), nil
+
+}
+
+// Show_lldp_neighbors runs s.Show_lldp_neighbors against Default_store.
+func Show_lldp_neighbors(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_lldp_neighbors(switch_id, switch_hostname)
+}
+
+// Show_lldp_neighbors_context runs s.Show_lldp_neighbors_context against Default_store.
+func Show_lldp_neighbors_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_lldp_neighbors_context(ctx, switch_id, switch_hostname)
+}
+
+// session_show_lldp_neighbors runs "show lldp neighbors" over the session of ctx and parses it like cisco.Show_lldp_neighbors.
+// A switch with LLDP disabled has no neighbor table, which is logged and stores nothing.
+func (s *Store) session_show_lldp_neighbors(ctx context.Context, switch_hostname string) ([]cisco.LldpNeighbor, error) {
+	outputString, err := s.run_command(ctx, switch_hostname, "show lldp neighbors")
+	if err != nil {
+		return nil, err
+	}
+
+	show_lldp_neighbors_data, err := parseLldpNeighbors(outputString)
+	if err != nil {
+		log.Printf("%s ::Show LLDP Neighbors :: Error during parsing: %v", switch_hostname, err)
+	}
+	for i := range show_lldp_neighbors_data {
+		show_lldp_neighbors_data[i].Interface = normalize_interface_name(show_lldp_neighbors_data[i].Interface)
+		show_lldp_neighbors_data[i].NeighborInterface = normalize_interface_name(show_lldp_neighbors_data[i].NeighborInterface)
+	}
+	return show_lldp_neighbors_data, nil
+}
+
+// parseLldpNeighbors processes the raw CLI output from "show lldp neighbors".
+func parseLldpNeighbors(rawOutput string) ([]cisco.LldpNeighbor, error) {
+	var neighbors []cisco.LldpNeighbor
+	lines := strings.Split(rawOutput, "\n")
+
+	headerLine := ""
+	dataStartIndex := -1
+
+	// Find the header line
+	for i, line := range lines {
+		if strings.HasPrefix(line, "Device ID") {
+			headerLine = line
+			dataStartIndex = i + 1
+			break
+		}
+	}
+
+	if dataStartIndex == -1 {
+		log.Println("LLDP neighbors header not found, returning empty list.")
+		return neighbors, nil
+	}
+
+	// Determine start indices of each column
+	deviceIDIndex := strings.Index(headerLine, "Device ID")
+	localIntfIndex := strings.Index(headerLine, "Local Intf")
+	holdtmeIndex := strings.Index(headerLine, "Hold-time")
+	capabilityIndex := strings.Index(headerLine, "Capability")
+	portIDIndex := strings.Index(headerLine, "Port ID")
+
+	if deviceIDIndex == -1 || localIntfIndex == -1 || holdtmeIndex == -1 || capabilityIndex == -1 || portIDIndex == -1 {
+		return nil, fmt.Errorf("could not parse LLDP neighbors header columns")
+	}
+
+	for i := dataStartIndex; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if strings.TrimSpace(line) == "" || strings.Contains(line, "Total entries displayed") {
+			continue
+		}
+		if len(line) < portIDIndex {
+			continue
+		}
+
+		neighbor := cisco.LldpNeighbor{
+			Interface:         strings.TrimSpace(line[localIntfIndex:holdtmeIndex]),
+			Neighbor:          strings.TrimSpace(line[deviceIDIndex:localIntfIndex]),
+			NeighborInterface: strings.TrimSpace(line[portIDIndex:]),
+			HoldTime:          strings.TrimSpace(line[holdtmeIndex:capabilityIndex]),
+			Capability:        strings.TrimSpace(line[capabilityIndex:portIDIndex]),
+		}
+
+		neighbors = append(neighbors, neighbor)
+	}
 
+	return neighbors, nil
 }
@@ -1,12 +1,11 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"regexp"
 	"strings"
-
-	"github.com/xtokio/cisco"
 )
 
 // MacAddressEntry defines the structure for a single entry in the MAC address table.
@@ -18,54 +17,60 @@ type MacAddressEntry struct {
 }
 
 // Show_mac_address_table constructs the command, runs it, and processes the output.
-func Show_mac_address_table(switch_id int64, switch_hostname string) error {
-	interfaces := Mac_address_table_interfaces(switch_id)
+func (s *Store) Show_mac_address_table(switch_id int64, switch_hostname string) error {
+	return s.Show_mac_address_table_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_mac_address_table_context is Show_mac_address_table with a context that cancels the collection and its database writes.
+func (s *Store) Show_mac_address_table_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_mac_address_table", func(ctx context.Context) (int64, error) {
+		return s.show_mac_address_table(ctx, switch_id, switch_hostname)
+	})
+}
+
+// show_mac_address_table collects the command output and returns the number of rows stored.
+func (s *Store) show_mac_address_table(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	interfaces := s.Mac_address_table_interfaces_context(ctx, switch_id)
 	if len(interfaces) == 0 {
 		log.Printf("%s :: mac address-table records not found in Database", switch_hostname)
-		return nil
+		return 0, nil
 	}
 	interfacesFilter, ok := interfaces[0]["interfaces"].(string)
 	if !ok || interfacesFilter == "" {
 		// Log and return if the key is missing, not a string, or is an empty string
 		log.Printf("%s :: interfaces[0][\"interfaces\"] is missing, nil, or not a string. Cannot proceed.", switch_hostname)
-		return nil
+		return 0, nil
 	}
 
 	// 1. Construct the Cisco command
 	command := fmt.Sprintf("show mac address-table | exclude %s", interfacesFilter)
 
-	outputString, err := cisco.RunCommand(switch_hostname, command)
+	outputString, err := s.run_command(ctx, switch_hostname, command)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// 2. Parse the output
 	mac_table_data, err := parseMacAddressTable(outputString)
 	if err != nil {
 		log.Printf("Error during parsing 'show mac address-table' output for %s: %v", switch_hostname, err)
-		return fmt.Errorf("error during parsing 'show mac address-table' output for %s: %v", switch_hostname, err)
+		return 0, fmt.Errorf("error during parsing 'show mac address-table' output for %s: %v", switch_hostname, err)
 	}
 
 	if len(mac_table_data) == 0 {
 		log.Printf("Show MAC Address Table :: Warning: Parsing completed for %s, but no MAC entries were found for filter '%s'.", switch_hostname, interfacesFilter)
-		return nil
-	}
-
-	db, err := DB_connect()
-	if err != nil {
-		log.Print(err)
+		return 0, nil
 	}
-	defer db.Close()
 
-	// Delete records
-	deleteQuery := fmt.Sprintf("DELETE FROM mac_address_table WHERE switch_id = %d AND DATE(created_at) = CURDATE()", switch_id)
-	Execute_query(db, deleteQuery)
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM mac_address_table WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
 
 	// Start the database transaction
-	tx, err := db.Begin()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 	// Defer a rollback. If tx.Commit() is called, this becomes a no-op.
 	defer tx.Rollback()
@@ -74,8 +79,8 @@ func Show_mac_address_table(switch_id int64, switch_hostname string) error {
 	const batchSize = 1000
 
 	// These are the "template" parts of your query
-	sqlStr := "INSERT INTO `mac_address_table` (`switch_id`, `interface`, `mac_address`, `vlan_id`, `type`) VALUES "
-	placeholderRow := "(?, ?, ?, ?, ?)"
+	sqlStr := "INSERT INTO `mac_address_table` (`switch_id`, `run_id`, `interface`, `mac_address`, `vlan_id`, `type`) VALUES "
+	placeholderRow := "(?, ?, ?, ?, ?, ?)"
 
 	// Iterate over the mac_table_data in chunks of 'batchSize'
 	for i := 0; i < len(mac_table_data); i += batchSize {
@@ -98,6 +103,7 @@ func Show_mac_address_table(switch_id int64, switch_hostname string) error {
 			// Add the values for this row
 			valueArgs = append(valueArgs,
 				switch_id,
+				run_arg(ctx),
 				details.Interface,
 				details.MacAddress,
 				details.VlanID,
@@ -108,15 +114,15 @@ func Show_mac_address_table(switch_id int64, switch_hostname string) error {
 		// Construct the final query *for this batch*
 		finalQuery := sqlStr + strings.Join(valueStrings, ",")
 
-		// Use tx.Exec() with the batch's query and values
-		_, err = tx.Exec(finalQuery, valueArgs...)
+		// Use tx.ExecContext() with the batch's query and values
+		_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 		if err != nil {
 			// If ANY batch fails, roll back the entire transaction
 			// This is the ONLY place Rollback should be called.
 			// tx.Rollback()
 			log.Printf("Failed to execute bulk insert batch for %s: %v", switch_hostname, err)
 			log.Printf("Failed query: %s", finalQuery) // Log the query for debugging
-			return err
+			return 0, err
 		}
 	} // --- End of batch loop ---
 
@@ -125,13 +131,13 @@ func Show_mac_address_table(switch_id int64, switch_hostname string) error {
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
 	// Log the success message
 	log.Printf("%d :: %s :: Show MAC Address Table :: %d records found.\n", switch_id, switch_hostname, len(mac_table_data))
 
-	return nil
+	return int64(len(mac_table_data)), nil
 }
 
 // parseMacAddressTable takes the raw output and extracts MacAddressEntry structs.
@@ -167,3 +173,21 @@ func parseMacAddressTable(rawOutput string) ([]MacAddressEntry, error) {
 
 	return macEntries, nil
 }
+
+// Show_mac_address_table runs s.Show_mac_address_table against Default_store.
+func Show_mac_address_table(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_mac_address_table(switch_id, switch_hostname)
+}
+
+// Show_mac_address_table_context runs s.Show_mac_address_table_context against Default_store.
+func Show_mac_address_table_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_mac_address_table_context(ctx, switch_id, switch_hostname)
+}
@@ -1,6 +1,7 @@
 package cisco_database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -29,50 +30,78 @@ type PowerInterfaceInfo struct {
 }
 
 // Show_power_inline fetches and processes "show power inline" output.
-func Show_power_inline(switch_id int64, switch_hostname string) error {
-	show_power_inline_modules_data, show_power_inline_interfaces_data, err := cisco.Show_power_inline(switch_hostname)
-	if err != nil {
-		return err
-	}
+func (s *Store) Show_power_inline(switch_id int64, switch_hostname string) error {
+	return s.Show_power_inline_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_power_inline_context is Show_power_inline with a context that cancels the collection and its database writes.
+func (s *Store) Show_power_inline_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_power_inline", func(ctx context.Context) (int64, error) {
+		return s.show_power_inline(ctx, switch_id, switch_hostname)
+	})
+}
 
-	// --- DATABASE OPERATIONS ---
-	db, err := DB_connect()
+// show_power_inline collects the command output and returns the number of rows stored.
+func (s *Store) show_power_inline(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	var power_inline_data powerInline
+	var err error
+	if in_session(ctx, switch_hostname) {
+		power_inline_data, err = s.session_show_power_inline(ctx, switch_hostname)
+	} else {
+		power_inline_data, err = retry_command(ctx, s.retry_policy(), switch_hostname, "show power inline", func() (powerInline, error) {
+			modules, interfaces, err := cisco.Show_power_inline(switch_hostname)
+			return powerInline{modules, interfaces}, err
+		})
+	}
 	if err != nil {
-		log.Print(err)
+		return 0, err
 	}
-	defer db.Close()
+	show_power_inline_modules_data := power_inline_data.modules
+	show_power_inline_interfaces_data := power_inline_data.interfaces
+
+	var rows int64
 
 	// Process Modules
 	if len(show_power_inline_modules_data) > 0 {
-		processPowerModules(db, switch_id, switch_hostname, show_power_inline_modules_data)
+		inserted, err := processPowerModules(ctx, s.DB, switch_id, switch_hostname, show_power_inline_modules_data)
+		if err != nil {
+			return rows, err
+		}
+		rows += inserted
 	} else {
 		log.Printf("Warning: No power modules found for %s.", switch_hostname)
 	}
 
 	// Process Interfaces
 	if len(show_power_inline_interfaces_data) > 0 {
-		processPowerInterfaces(db, switch_id, switch_hostname, show_power_inline_interfaces_data)
+		inserted, err := processPowerInterfaces(ctx, s.DB, switch_id, switch_hostname, show_power_inline_interfaces_data)
+		if err != nil {
+			return rows, err
+		}
+		rows += inserted
 	} else {
 		log.Printf("Warning: No power interfaces found for %s.", switch_hostname)
 	}
 
-	return nil
+	return rows, nil
 }
 
 // processPowerModules handles the bulk insert for power modules.
-func processPowerModules(db *sql.DB, switch_id int64, switch_hostname string, modules []cisco.PowerModuleInfo) {
-	deleteQuery := fmt.Sprintf("DELETE FROM power_modules WHERE switch_id = %d", switch_id)
-	Execute_query(db, deleteQuery)
+func processPowerModules(ctx context.Context, db *sql.DB, switch_id int64, switch_hostname string, modules []cisco.PowerModuleInfo) (int64, error) {
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM power_modules WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, db, deleteQuery, switch_id, run_arg(ctx))
 
-	sqlStr := "INSERT INTO `power_modules` (`switch_id`, `module`, `available`, `used`, `remaining`) VALUES "
+	sqlStr := "INSERT INTO `power_modules` (`switch_id`, `run_id`, `module`, `available`, `used`, `remaining`) VALUES "
 	var valueStrings []string
 	var valueArgs []any
-	placeholderRow := "(?, ?, ?, ?, ?)"
+	placeholderRow := "(?, ?, ?, ?, ?, ?)"
 
 	for _, mod := range modules {
 		valueStrings = append(valueStrings, placeholderRow)
 		valueArgs = append(valueArgs,
 			switch_id,
+			run_arg(ctx),
 			mod.Module,
 			mod.Available,
 			mod.Used,
@@ -81,38 +110,46 @@ func processPowerModules(db *sql.DB, switch_id int64, switch_hostname string, mo
 	}
 
 	finalQuery := sqlStr + strings.Join(valueStrings, ",")
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s (modules): %v", switch_hostname, err)
-		return
+		return 0, err
 	}
 
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s (modules): %v", switch_hostname, err)
-		return
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Failed to commit bulk insert transaction for %s (modules): %v", switch_hostname, err)
+		return 0, err
 	}
-	tx.Commit()
 
 	log.Printf("%d :: %s :: Show Power Modules :: %d records inserted.\n", switch_id, switch_hostname, len(modules))
+
+	return int64(len(modules)), nil
 }
 
 // processPowerInterfaces handles the bulk insert for power interfaces.
-func processPowerInterfaces(db *sql.DB, switch_id int64, switch_hostname string, interfaces []cisco.PowerInterfaceInfo) {
-	deleteQuery := fmt.Sprintf("DELETE FROM power_interfaces WHERE switch_id = %d", switch_id)
-	Execute_query(db, deleteQuery)
+func processPowerInterfaces(ctx context.Context, db *sql.DB, switch_id int64, switch_hostname string, interfaces []cisco.PowerInterfaceInfo) (int64, error) {
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM power_interfaces WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, db, deleteQuery, switch_id, run_arg(ctx))
 
 	// Note: Column names like 'interface' and 'class' might be reserved keywords; use backticks.
-	sqlStr := "INSERT INTO `power_interfaces` (`switch_id`, `interface`, `admin`, `oper`, `power`, `device`, `class`, `max`) VALUES "
+	sqlStr := "INSERT INTO `power_interfaces` (`switch_id`, `run_id`, `interface`, `admin`, `oper`, `power`, `device`, `class`, `max`) VALUES "
 	var valueStrings []string
 	var valueArgs []any
-	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?)"
+	placeholderRow := "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
 
 	for _, iface := range interfaces {
 		valueStrings = append(valueStrings, placeholderRow)
 		valueArgs = append(valueArgs,
 			switch_id,
+			run_arg(ctx),
 			iface.Interface,
 			iface.Admin,
 			iface.Oper,
@@ -124,19 +161,154 @@ func processPowerInterfaces(db *sql.DB, switch_id int64, switch_hostname string,
 	}
 
 	finalQuery := sqlStr + strings.Join(valueStrings, ",")
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s (interfaces): %v", switch_hostname, err)
-		return
+		return 0, err
 	}
 
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s (interfaces): %v", switch_hostname, err)
-		return
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Failed to commit bulk insert transaction for %s (interfaces): %v", switch_hostname, err)
+		return 0, err
 	}
-	tx.Commit()
 
 	log.Printf("%d :: %s :: Show Power Interfaces :: %d records inserted.\n", switch_id, switch_hostname, len(interfaces))
+
+	return int64(len(interfaces)), nil
+}
+
+// Show_power_inline runs s.Show_power_inline against Default_store.
+func Show_power_inline(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_power_inline(switch_id, switch_hostname)
+}
+
+// Show_power_inline_context runs s.Show_power_inline_context against Default_store.
+func Show_power_inline_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_power_inline_context(ctx, switch_id, switch_hostname)
+}
+
+// powerInline is the module and interface sections of "show power inline";
+// retry_command returns a single value, so both slices travel together.
+type powerInline struct {
+	modules    []cisco.PowerModuleInfo
+	interfaces []cisco.PowerInterfaceInfo
+}
+
+// session_show_power_inline runs "show power inline" over the session of ctx and parses it like cisco.Show_power_inline.
+// Switches without PoE have neither section, which is not an error.
+func (s *Store) session_show_power_inline(ctx context.Context, switch_hostname string) (powerInline, error) {
+	outputString, err := s.run_command(ctx, switch_hostname, "show power inline")
+	if err != nil {
+		return powerInline{}, err
+	}
+
+	modules, interfaces, err := parsePowerInline(outputString)
+	if err != nil {
+		log.Printf("Show power inline :: Warning :: Parsing completed for %s: %v", switch_hostname, err)
+	}
+	return powerInline{modules, interfaces}, nil
+}
+
+// parsePowerInline processes the raw CLI output from "show power inline".
+// It splits parsing into two sections and returns two different slices.
+func parsePowerInline(rawOutput string) ([]cisco.PowerModuleInfo, []cisco.PowerInterfaceInfo, error) {
+	var modules []cisco.PowerModuleInfo
+	var interfaces []cisco.PowerInterfaceInfo
+	lines := strings.Split(rawOutput, "\n")
+
+	// Define states for our state machine parser
+	type section int
+	const (
+		None section = iota
+		Module
+		Interface
+	)
+	currentSection := None
+
+	for _, line := range lines {
+		// Get fields *first* to handle all whitespace types.
+		fields := strings.Fields(line)
+
+		// --- 1. State Detection ---
+
+		// If it's a blank line, reset the state.
+		if len(fields) == 0 {
+			currentSection = None
+			continue
+		}
+
+		// Check the *first field* to determine the section.
+		// This is the ONLY place we should change the state,
+		// aside from a blank line.
+		switch fields[0] {
+		case "Module":
+			currentSection = Module
+			continue // Skip this header line
+		case "Interface":
+			currentSection = Interface
+			continue // Skip this header line
+		}
+
+		// --- 2. State-Based Parsing ---
+		//
+		// --- FIX: NO 'else { currentSection = None }' BLOCKS ---
+		// We just ignore lines that don't match our data format.
+		// The state stays the same until a new header or blank line.
+
+		switch currentSection {
+		case Module:
+			// A valid data line has 4 fields and does NOT start with "------"
+			if len(fields) == 4 && !strings.HasPrefix(fields[0], "---") {
+				mod := cisco.PowerModuleInfo{
+					Module:    fields[0],
+					Available: fields[1],
+					Used:      fields[2],
+					Remaining: fields[3],
+				}
+				modules = append(modules, mod)
+			}
+			// If it's the "(Watts)" line or "------" line, it fails the 'if'
+			// and is simply ignored, without changing the state.
+
+		case Interface:
+			// A valid data line has >= 6 fields and the first field
+			// must look like an interface name (e.g., contains '/')
+			if len(fields) >= 6 && strings.Contains(fields[0], "/") {
+				iface := cisco.PowerInterfaceInfo{
+					Interface: fields[0],
+					Admin:     fields[1],
+					Oper:      fields[2],
+					Power:     fields[3],
+					// Join all fields between Power and Class
+					Device: strings.Join(fields[4:len(fields)-2], " "),
+					Class:  fields[len(fields)-2],
+					Max:    fields[len(fields)-1],
+				}
+				interfaces = append(interfaces, iface)
+			}
+			// If it's the "(Watts)" line, "------", or "Totals:" line,
+			// it fails the 'if' and is ignored, without changing the state.
+		}
+	} // end for loop
+
+	if len(modules) == 0 && len(interfaces) == 0 {
+		return nil, nil, fmt.Errorf("could not find module or interface data in output")
+	}
+
+	return modules, interfaces, nil
 }
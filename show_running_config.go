@@ -1,12 +1,10 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"regexp"
 	"strings"
-
-	"github.com/xtokio/cisco"
 )
 
 type InterfaceConfig struct {
@@ -14,35 +12,63 @@ type InterfaceConfig struct {
 	ConfigLines []string
 }
 
-// Show_running_config executes the command, parses the interface configs, and saves them to the DB.
-func Show_running_config(switch_id int64, switch_hostname string) error {
-	interfaceConfigs, err := cisco.Show_running_config(switch_hostname)
+// Show_running_config executes the command, archives the full config, and saves the interface configs to the DB.
+func (s *Store) Show_running_config(switch_id int64, switch_hostname string) error {
+	return s.Show_running_config_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_running_config_context is Show_running_config with a context that cancels the collection and its database writes.
+func (s *Store) Show_running_config_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_running_config", func(ctx context.Context) (int64, error) {
+		return s.show_running_config(ctx, switch_id, switch_hostname)
+	})
+}
+
+// show_running_config collects the command output and returns the number of rows stored.
+// The full configuration is archived by Archive_config, the interface blocks go to show_running_config.
+func (s *Store) show_running_config(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	rawOutput, err := s.run_command(ctx, switch_hostname, "show running-config")
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if len(interfaceConfigs) == 0 {
-		log.Printf("Show Running-Config :: Warning: Parsing completed for %s, but no interfaces were found.", switch_hostname)
-		return nil
+	config := Clean_running_config(rawOutput)
+	if strings.TrimSpace(config) == "" {
+		return 0, fmt.Errorf("error: empty running-config from %s", switch_hostname)
 	}
 
-	// 3. Establish the database connection.
-	db, err := DB_connect() // Using mock
+	version, created, err := s.Archive_config(ctx, switch_id, config)
 	if err != nil {
-		log.Print(err)
-		return err
+		log.Printf("%d :: %s :: Show Running-Config :: %v", switch_id, switch_hostname, err)
+		return 0, err
+	}
+	// Archive_config removed the secrets, the git backup and the interface blocks use its text.
+	config = version.Config
+	if created {
+		log.Printf("%d :: %s :: Show Running-Config :: new config version %d archived.\n", switch_id, switch_hostname, version.ID)
+	}
+
+	if s.Exporter != nil {
+		if err := s.Exporter.Write(Run_from_context(ctx), switch_hostname, config); err != nil {
+			log.Printf("%d :: %s :: Show Running-Config :: %v", switch_id, switch_hostname, err)
+		}
+	}
+
+	interfaceConfigs := interface_configs(Parse_config_tree(config))
+	if len(interfaceConfigs) == 0 {
+		log.Printf("Show Running-Config :: Warning: Parsing completed for %s, but no interfaces were found.", switch_hostname)
+		return 1, nil
 	}
-	defer db.Close()
 
-	// Delete existing records
-	deleteQuery := fmt.Sprintf("DELETE FROM show_running_config WHERE switch_id = %d", switch_id)
-	Execute_query(db, deleteQuery)
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM show_running_config WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
 
 	// 5. Prepare for bulk insert (adapting the logic from show_vlan.go)
-	sqlStr := "INSERT INTO `show_running_config` (`switch_id`, `interface`, `configuration`) VALUES "
+	sqlStr := "INSERT INTO `show_running_config` (`switch_id`, `run_id`, `interface`, `configuration`) VALUES "
 	var valueStrings []string
 	var valueArgs []any
-	placeholderRow := "(?, ?, ?)" // switch_id, interface_name, configuration
+	placeholderRow := "(?, ?, ?, ?)" // switch_id, run_id, interface_name, configuration
 
 	for _, cfg := range interfaceConfigs {
 		valueStrings = append(valueStrings, placeholderRow)
@@ -53,6 +79,7 @@ func Show_running_config(switch_id int64, switch_hostname string) error {
 		// Add the values for this row
 		valueArgs = append(valueArgs,
 			switch_id,
+			run_arg(ctx),
 			cfg.Interface,
 			configBlock,
 		)
@@ -60,86 +87,84 @@ func Show_running_config(switch_id int64, switch_hostname string) error {
 
 	// 6. Execute the bulk insert within a transaction
 	finalQuery := sqlStr + strings.Join(valueStrings, ",")
-	tx, err := db.Begin()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s: %v", switch_hostname, err)
 		log.Printf("Failed query: %s", finalQuery)
-		return err
+		return 0, err
 	}
 
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
 	log.Printf("%d :: %s :: Show Running-Config :: %d records inserted.\n", switch_id, switch_hostname, len(interfaceConfigs))
 
-	return nil
+	// The archived config counts as one more row stored.
+	return int64(len(interfaceConfigs)) + 1, nil
 }
 
-// --- PARSING FUNCTION ---
-
-// parseInterfaceConfig processes the raw CLI output from "show running-config"
-// to extract the configuration block for each interface.
-func parseInterfaceConfig(rawOutput string) ([]InterfaceConfig, error) {
+// interface_configs returns the interface sections of a running-config tree.
+func interface_configs(tree *ConfigNode) []InterfaceConfig {
 	var configs []InterfaceConfig
-	lines := strings.Split(rawOutput, "\n")
-
-	// Regex to match the start of an interface block: "interface <name>"
-	// It captures the interface name group (e.g., FastEthernet0/1, Vlan1, Port-channel1)
-	interfaceStartRegex := regexp.MustCompile(`^interface\s+(\S+)$`)
-
-	var currentConfig *InterfaceConfig = nil
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line) // Remove leading/trailing whitespace
-
-		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "version") || strings.HasPrefix(line, "hostname") {
-			// Skip empty lines, '!', and global configuration lines for simplicity.
-			// A more robust parser might track indentation to properly skip non-interface blocks.
-			continue
-		}
-
-		// Check for the start of a new interface block
-		if matches := interfaceStartRegex.FindStringSubmatch(line); len(matches) > 1 {
-			// 1. If we were already in an interface block, save the previous one.
-			if currentConfig != nil {
-				configs = append(configs, *currentConfig)
-			}
-
-			// 2. Start a new interface block
-			interfaceName := matches[1]
-			currentConfig = &InterfaceConfig{
-				Interface: interfaceName,
-				// Include the 'interface <name>' line itself as the first config line
-				ConfigLines: []string{line},
-			}
-		} else if currentConfig != nil {
-			// If we are currently inside an interface block, and the line is NOT a new 'interface' command,
-			// it must be a sub-command (e.g., 'switchport access vlan 10').
-			// The original 'show running-config' output uses indentation for sub-commands,
-			// but TrimSpace(line) handles that.
-			currentConfig.ConfigLines = append(currentConfig.ConfigLines, line)
-		}
-		// If currentConfig is nil, we are in the global config block, so we ignore the line (due to the initial 'continue' checks).
+	for _, section := range tree.Find("interface") {
+		configs = append(configs, InterfaceConfig{
+			Interface:   normalize_interface_name(strings.TrimPrefix(section.Line, "interface ")),
+			ConfigLines: section.Lines(),
+		})
 	}
+	return configs
+}
 
-	// Append the *last* collected configuration block, if one exists.
-	if currentConfig != nil {
-		configs = append(configs, *currentConfig)
-	}
+// normalize_interface_name shortens interface names the way the cisco package does,
+// so the interface column joins with the other tables.
+func normalize_interface_name(name string) string {
+	name = strings.ReplaceAll(name, " ", "")
+
+	replacer := strings.NewReplacer(
+		"AppGigabitEthernet", "Ap",
+		"FastEthernet", "Fa",
+		"GigabitEthernet", "Gi",
+		"FiveGigabitEthernet", "Fi",
+		"FiveGi", "Fi",
+		"Fiv", "Fi",
+		"TenGigabitEthernet", "Te",
+		"TenGi", "Te",
+		"Ten", "Te",
+		"TwentyGigabitEthernet", "Twe",
+		"TwentyFiveGigE", "Twe",
+		"TwentyFigE", "Twe",
+		"FortyGigabitEthernet", "Fo",
+		"FortyGi", "Fo",
+		"HundredGigE", "Hu",
+		"Gig", "Gi",
+	)
+	return replacer.Replace(name)
+}
 
-	if len(configs) == 0 {
-		return nil, fmt.Errorf("no interface configurations found")
+// Show_running_config runs s.Show_running_config against Default_store.
+func Show_running_config(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
 	}
+	return s.Show_running_config(switch_id, switch_hostname)
+}
 
-	return configs, nil
+// Show_running_config_context runs s.Show_running_config_context against Default_store.
+func Show_running_config_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_running_config_context(ctx, switch_id, switch_hostname)
 }
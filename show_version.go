@@ -1,8 +1,12 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"reflect"
+	"regexp"
+	"strings"
 
 	"github.com/xtokio/cisco"
 )
@@ -22,25 +26,38 @@ type VersionInfo struct {
 }
 
 // Show_version connects to a switch, runs "show version", and returns the parsed data as a map.
-func Show_version(switch_id int64, switch_hostname string) error {
-	show_version_data, err := cisco.Show_version(switch_hostname)
-	if err != nil {
-		return err
-	}
-
-	process_show_version(show_version_data, switch_id, switch_hostname)
+func (s *Store) Show_version(switch_id int64, switch_hostname string) error {
+	return s.Show_version_context(context.Background(), switch_id, switch_hostname)
+}
 
-	return nil
+// Show_version_context is Show_version with a context that cancels the collection and its database writes.
+func (s *Store) Show_version_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	_, err := s.show_version(ctx, switch_id, switch_hostname)
+	return err
 }
 
-func process_show_version(versionData map[string]string, switch_id int64, switch_hostname string) {
-	// Establish the database connection.
-	db, err := DB_connect()
+// show_version collects the command output and returns the number of rows stored.
+func (s *Store) show_version(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	var show_version_data map[string]string
+	var err error
+	if in_session(ctx, switch_hostname) {
+		show_version_data, err = s.session_show_version(ctx, switch_hostname)
+	} else {
+		show_version_data, err = retry_command(ctx, s.retry_policy(), switch_hostname, "show version", func() (map[string]string, error) {
+			return cisco.Show_version(switch_hostname)
+		})
+	}
 	if err != nil {
-		log.Print(err)
+		return 0, err
 	}
-	defer db.Close()
-	updateQuery := fmt.Sprintf("UPDATE switches SET `hardware` = '%s', `version` = '%s', `release` = '%s', `software_image` = '%s', `serial` = '%s', `uptime` = '%s', `restarted` = '%s', `reload_reason` = '%s', `rommon` = '%s' WHERE id = %d",
+
+	return s.process_show_version(ctx, show_version_data, switch_id, switch_hostname)
+}
+
+func (s *Store) process_show_version(ctx context.Context, versionData map[string]string, switch_id int64, switch_hostname string) (int64, error) {
+	updateQuery := "UPDATE switches SET `hardware` = ?, `version` = ?, `release` = ?, `software_image` = ?, `serial` = ?, `uptime` = ?, `restarted` = ?, `reload_reason` = ?, `rommon` = ? WHERE id = ?"
+
+	affectedRows, err := Execute_query_context(ctx, s.DB, updateQuery,
 		versionData["Hardware"],
 		versionData["Version"],
 		versionData["Release"],
@@ -52,11 +69,125 @@ func process_show_version(versionData map[string]string, switch_id int64, switch
 		versionData["Rommon"],
 		switch_id,
 	)
-
-	affectedRows, err := Execute_query(db, updateQuery)
 	if err != nil {
 		log.Printf("Update query failed: %v", err)
-	} else {
-		log.Printf("%d :: %s :: Show Version :: UPDATE successful. Rows affected: %d\n", switch_id, switch_hostname, affectedRows)
+		return 0, err
+	}
+	log.Printf("%d :: %s :: Show Version :: UPDATE successful. Rows affected: %d\n", switch_id, switch_hostname, affectedRows)
+
+	return affectedRows, nil
+}
+
+// Show_version runs s.Show_version against Default_store.
+func Show_version(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_version(switch_id, switch_hostname)
+}
+
+// Show_version_context runs s.Show_version_context against Default_store.
+func Show_version_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_version_context(ctx, switch_id, switch_hostname)
+}
+
+// session_show_version runs "show version" over the session of ctx and parses it like cisco.Show_version.
+func (s *Store) session_show_version(ctx context.Context, switch_hostname string) (map[string]string, error) {
+	outputString, err := s.run_command(ctx, switch_hostname, "show version")
+	if err != nil {
+		return nil, err
 	}
+
+	show_version_data, err := parseVersionInfo(outputString)
+	if err != nil {
+		log.Printf("Error parsing 'show version' output for %s: %v", switch_hostname, err)
+		return nil, &CollectError{Class: ErrorParse, Attempts: 1, Err: fmt.Errorf("error parsing 'show version' output for %s: %v", switch_hostname, err)}
+	}
+	return show_version_data, nil
+}
+
+// parseVersionInfo processes the raw CLI output from "show version".
+// It returns a map of string keys to string values.
+func parseVersionInfo(rawOutput string) (map[string]string, error) {
+	var info VersionInfo
+	result := make(map[string]string) // Initialize the map to be returned
+
+	// Define regular expressions for each piece of data we want to capture.
+	regexes := map[string]*regexp.Regexp{
+		// Hardware: (IOS/IE1000) | (Nexus: Chassis name)
+		"Hardware": regexp.MustCompile(`(?i)cisco ([\w-]+[a-z\d\-]+) .* processor|Board Type\s*:\s*(\S+)|Product\s*:\s*Cisco ([\w\s]+) Switch|cisco (Nexus\S+ [\w-]+ Chassis)|cisco ([\w-]+ Chassis)`),
+
+		// Version: (IOS) | (IE1000) | (Nexus: system version)
+		"Version": regexp.MustCompile(`(?i)Version ([^,]+),|NXOS:\s*version\s*(\S+).*|Active Image\s*:\s*.*?\nVersion\s*:\s*(\S+)|Software Version\s*:\s*(\S+)|system:\s*version\s*(\S+)`),
+
+		// Release: (IOS only, not easily mapped for NX-OS/IE1000)
+		"Release": regexp.MustCompile(`(?i)Version [^,]+, (RELEASE SOFTWARE .*)`),
+
+		// SoftwareImage: (IOS) | (IE1000) | (Nexus: system image file)
+		"SoftwareImage": regexp.MustCompile(`(?i)System image file is "([^"]+)"|NXOS image file is:\s*(\S+)|Active Image\s*:\s*([^\s(]+)|system image file is:\s*(\S+)`),
+
+		// SerialNumber: (IOS: System/Processor ID) | (IE1000: MAC Address) | (Nexus: Processor Board ID)
+		"SerialNumber": regexp.MustCompile(`(?i)(?:System serial number\s*:\s*(\S+)|Processor board ID\s*(\S+)|MAC Address\s*:\s*(\S+)|Processor Board ID\s*(\S+))`),
+
+		// Uptime: (IOS) | (IE1000) | (Nexus: Kernel uptime)
+		"Uptime": regexp.MustCompile(`(?i)uptime is (.+)|System Uptime\s*:\s*(\S+)|Kernel uptime is (.+)`),
+
+		// Restarted: (IOS) | (IE1000) | (Nexus: Last reset reason) - Nexus uses Last Reset/Reason instead of 'Restarted At'
+		// We'll capture the time-like string from the IOS/IE1000, or the Reason/System Version from Nexus.
+		"Restarted": regexp.MustCompile(`(?i)System restarted at (.*)|Previous Restart\s*:\s*(.*)|Last reset\s*\n\s*Reason:\s*(\S+)`),
+
+		// ReloadReason: (IOS) | (Nexus: Last reset reason)
+		"ReloadReason": regexp.MustCompile(`(?i)(?:Last reload reason: (.*)|System returned to ROM by (.*)|Last reset\s*\n\s*Reason:\s*(.*))`),
+
+		// Rommon: (IOS: ROM) | (IE1000: Bootloader) | (Nexus: BIOS)
+		"Rommon": regexp.MustCompile(`(?i)ROM: (.*)|Bootloader\s*:\s*(\S+)|BIOS:\s*version\s*(\S+)`),
+	}
+
+	// Use reflection to dynamically match regexes to struct fields
+	v := reflect.ValueOf(&info).Elem()
+	t := v.Type()
+
+	for _, line := range strings.Split(rawOutput, "\n") {
+		cleanLine := strings.TrimSpace(line)
+		for i := 0; i < v.NumField(); i++ {
+			fieldName := t.Field(i).Name
+			fieldValue := v.Field(i)
+
+			if fieldValue.String() == "" { // Only parse if not already found
+				if re, ok := regexes[fieldName]; ok {
+					if matches := re.FindStringSubmatch(cleanLine); len(matches) > 1 {
+						// Iterate over all subgroups to find the first non-empty match
+						for j := 1; j < len(matches); j++ {
+							match := strings.TrimSpace(matches[j])
+							if match != "" {
+								fieldValue.SetString(match)
+								break // Found the value for this field, move to next field
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Check if we found at least some data
+	if info.Version == "" || info.SerialNumber == "" {
+		return nil, fmt.Errorf("could not parse essential version info from output")
+	}
+
+	// Convert the populated struct to a map
+	for i := 0; i < v.NumField(); i++ {
+		value := v.Field(i).String()
+		if value != "" { // Only add keys for values that were found
+			key := t.Field(i).Name
+			result[key] = value
+		}
+	}
+
+	return result, nil
 }
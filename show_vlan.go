@@ -1,8 +1,10 @@
 package cisco_database
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
 
 	"github.com/xtokio/cisco"
@@ -16,36 +18,49 @@ type VlanInfo struct {
 	Ports    []string
 }
 
-func Show_vlan(switch_id int64, switch_hostname string) error {
-	show_vlan_data, err := cisco.Show_vlan(switch_hostname)
+func (s *Store) Show_vlan(switch_id int64, switch_hostname string) error {
+	return s.Show_vlan_context(context.Background(), switch_id, switch_hostname)
+}
+
+// Show_vlan_context is Show_vlan with a context that cancels the collection and its database writes.
+func (s *Store) Show_vlan_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	return s.in_run(ctx, "Show_vlan", func(ctx context.Context) (int64, error) {
+		return s.show_vlan(ctx, switch_id, switch_hostname)
+	})
+}
+
+// show_vlan collects the command output and returns the number of rows stored.
+func (s *Store) show_vlan(ctx context.Context, switch_id int64, switch_hostname string) (int64, error) {
+	var show_vlan_data []cisco.VlanInfo
+	var err error
+	if in_session(ctx, switch_hostname) {
+		show_vlan_data, err = s.session_show_vlan(ctx, switch_hostname)
+	} else {
+		show_vlan_data, err = retry_command(ctx, s.retry_policy(), switch_hostname, "show vlan", func() ([]cisco.VlanInfo, error) {
+			return cisco.Show_vlan(switch_hostname)
+		})
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Check the length of the slice, not the map.
 	if len(show_vlan_data) == 0 {
 		log.Printf("Show VLAN :: Warning: Parsing completed for %s, but no interfaces were found.", switch_hostname)
-		return nil
+		return 0, nil
 	}
 
-	// Establish the database connection.
-	db, err := DB_connect()
-	if err != nil {
-		log.Print(err)
-	}
-	defer db.Close()
+	// Replace the rows of this run when the step is re-run, older runs are kept until Prune
+	deleteQuery := "DELETE FROM vlans WHERE switch_id = ? AND run_id = ?"
+	Execute_query_context(ctx, s.DB, deleteQuery, switch_id, run_arg(ctx))
 
-	// Delete records
-	deleteQuery := fmt.Sprintf("DELETE FROM vlans WHERE switch_id = %d AND DATE(created_at) = CURDATE()", switch_id)
-	Execute_query(db, deleteQuery)
-
-	sqlStr := "INSERT INTO `vlans` (`switch_id`, `vlan_id`, `vlan_name`, `status`, `interfaces`) VALUES "
+	sqlStr := "INSERT INTO `vlans` (`switch_id`, `run_id`, `vlan_id`, `vlan_name`, `status`, `interfaces`) VALUES "
 	// 2. Create slices for the placeholder strings and the actual values
 	var valueStrings []string
 	var valueArgs []any // Use []any (or []interface{})
 
 	// This is the placeholder group for a SINGLE row (25 placeholders)
-	placeholderRow := "(?, ?, ?, ?, ?)"
+	placeholderRow := "(?, ?, ?, ?, ?, ?)"
 
 	// Iterate over the slice, which maintains the correct order.
 	for _, details := range show_vlan_data {
@@ -58,6 +73,7 @@ func Show_vlan(switch_id int64, switch_hostname string) error {
 		// Add the values for this row, in the *exact* same order as the columns above
 		valueArgs = append(valueArgs,
 			switch_id, // The switch_id from the function argument
+			run_arg(ctx),
 			details.VLANID,
 			details.VLANName,
 			details.Status,
@@ -65,31 +81,143 @@ func Show_vlan(switch_id int64, switch_hostname string) error {
 		)
 	}
 	finalQuery := sqlStr + strings.Join(valueStrings, ",")
-	tx, err := db.Begin()
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
-	// Use tx.Exec() with the final query and the flat slice of all values
-	_, err = tx.Exec(finalQuery, valueArgs...)
+	// Use tx.ExecContext() with the final query and the flat slice of all values
+	_, err = tx.ExecContext(ctx, finalQuery, valueArgs...)
 	if err != nil {
 		// Roll back the transaction if there's an error
 		tx.Rollback()
 		log.Printf("Failed to execute bulk insert for %s: %v", switch_hostname, err)
 		log.Printf("Failed query: %s", finalQuery) // Log the query for debugging
-		return err
+		return 0, err
 	}
 
 	// If successful, commit the transaction
 	err = tx.Commit()
 	if err != nil {
 		log.Printf("Failed to commit bulk insert transaction for %s: %v", switch_hostname, err)
-		return err
+		return 0, err
 	}
 
 	log.Printf("%d :: %s :: Show Vlans :: %d records inserted.\n", switch_id, switch_hostname, len(show_vlan_data))
 
-	return nil
+	return int64(len(show_vlan_data)), nil
+
+}
+
+// Show_vlan runs s.Show_vlan against Default_store.
+func Show_vlan(switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_vlan(switch_id, switch_hostname)
+}
+
+// Show_vlan_context runs s.Show_vlan_context against Default_store.
+func Show_vlan_context(ctx context.Context, switch_id int64, switch_hostname string) error {
+	s, err := Default_store()
+	if err != nil {
+		return err
+	}
+	return s.Show_vlan_context(ctx, switch_id, switch_hostname)
+}
+
+// session_show_vlan runs "show vlan" over the session of ctx and parses it like cisco.Show_vlan.
+func (s *Store) session_show_vlan(ctx context.Context, switch_hostname string) ([]cisco.VlanInfo, error) {
+	outputString, err := s.run_command(ctx, switch_hostname, "show vlan")
+	if err != nil {
+		return nil, err
+	}
+
+	show_vlan_data, err := parseVlanInfo(outputString)
+	if err != nil {
+		log.Printf("%s :: Show Vlans :: Error during parsing: %v", switch_hostname, err)
+		return nil, &CollectError{Class: ErrorParse, Attempts: 1, Err: err}
+	}
+	return show_vlan_data, nil
+}
+
+// parseVlanInfo processes the raw CLI output from "show vlan" and converts it into a list of cisco.VlanInfo structs.
+// This corrected version knows when to stop parsing and properly handles empty port lists.
+func parseVlanInfo(rawOutput string) ([]cisco.VlanInfo, error) {
+	var vlans []cisco.VlanInfo
+	lines := strings.Split(rawOutput, "\n")
+
+	// Regex to identify a line that starts a new VLAN entry (begins with a number).
+	isNewVlanLine := regexp.MustCompile(`^\d`)
+
+	dataStartIndex := -1
+	// Find the start of the data, which is 2 lines after the header "VLAN Name..."
+	for i, line := range lines {
+		if strings.HasPrefix(line, "VLAN Name") {
+			dataStartIndex = i + 2 // Skip header and separator line "----..."
+			break
+		}
+	}
+
+	if dataStartIndex == -1 {
+		return nil, fmt.Errorf("could not find VLAN header in output")
+	}
+
+	for i := dataStartIndex; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+
+		// *** FIX #1: Stop parsing before the second, unrelated table begins. ***
+		if strings.HasPrefix(line, "VLAN Type") {
+			break
+		}
+
+		if line == "" {
+			continue
+		}
+
+		if isNewVlanLine.MatchString(line) {
+			// This is a new VLAN entry
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue // Malformed line
+			}
+
+			// *** FIX #2: Initialize Ports as an empty slice to avoid 'null' in JSON. ***
+			vlan := cisco.VlanInfo{
+				VLANID:   fields[0],
+				VLANName: fields[1],
+				Status:   fields[2],
+				Ports:    make([]string, 0),
+			}
+
+			// If there are ports listed on this line, parse them
+			if len(fields) > 3 {
+				portStr := strings.Join(fields[3:], "")
+				ports := strings.Split(portStr, ",")
+				vlan.Ports = append(vlan.Ports, ports...)
+			}
+			vlans = append(vlans, vlan)
+		} else if len(vlans) > 0 {
+			// This is a continuation of the previous VLAN's port list
+			lastVlan := &vlans[len(vlans)-1]
+			portStr := strings.TrimSpace(line)
+			ports := strings.Split(portStr, ",")
+			lastVlan.Ports = append(lastVlan.Ports, ports...)
+		}
+	}
+
+	// Clean up empty strings from port lists
+	for i := range vlans {
+		var cleanPorts []string
+		for _, port := range vlans[i].Ports {
+			if trimmedPort := strings.TrimSpace(port); trimmedPort != "" {
+				cleanPorts = append(cleanPorts, trimmedPort)
+			}
+		}
+		vlans[i].Ports = cleanPorts
+	}
 
+	return vlans, nil
 }
@@ -0,0 +1,305 @@
+package cisco_database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtokio/cisco"
+	"golang.org/x/crypto/ssh"
+)
+
+// session_command_timeout bounds one command of a Session, as cisco.RunCommand does.
+const session_command_timeout = 30 * time.Second
+
+// session_prompt matches an exec prompt ending the output, e.g. "switch1#" or "switch1>".
+var session_prompt = regexp.MustCompile(`(?:^|\n)([^\s#>]+[>#])\s*$`)
+
+// Session is one authenticated SSH shell on a switch that runs every command of a collection,
+// instead of the login per command of the cisco package. It is not safe for concurrent use.
+// After a failed command the shell is closed and the next Run logs in again.
+type Session struct {
+	Fqdn string
+
+	mu      sync.Mutex
+	client  *ssh.Client
+	session *ssh.Session
+	stdin   io.WriteCloser
+	prompt  string
+	output  *session_output
+}
+
+// session_output receives the output of one shell. Each login has its own, so the read
+// goroutine of a closed shell cannot write into the next one.
+type session_output struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	err    error
+	notify chan struct{}
+}
+
+// Open_session logs in to the switch with the CISCO_USERNAME and CISCO_PASSWORD credentials,
+// waits for the prompt and disables paging.
+func Open_session(ctx context.Context, fqdn string) (*Session, error) {
+	session := &Session{Fqdn: fqdn}
+	if err := session.connect(ctx); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Run sends a command and returns its output up to the next prompt, with the prompt and the
+// echoed command in front, the same text cisco.RunCommand returns for it.
+func (session *Session) Run(ctx context.Context, command string) (string, error) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.client == nil {
+		log.Printf("%s :: Session :: reconnecting", session.Fqdn)
+		if err := session.connect(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	output, err := session.exec(ctx, command)
+	if err != nil {
+		// The shell may still be printing the output, it cannot be reused.
+		session.close()
+		return "", err
+	}
+	return session.prompt + output, nil
+}
+
+// Close logs out of the switch.
+func (session *Session) Close() error {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.client == nil {
+		return nil
+	}
+	fmt.Fprintf(session.stdin, "exit\n")
+	return session.close()
+}
+
+func (session *Session) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probe_timeout)
+	defer cancel()
+
+	client, err := dial_ssh(ctx, session.Fqdn)
+	if err != nil {
+		return fmt.Errorf("failed to dial SSH to %s: %w", session.Fqdn, err)
+	}
+	shell, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("failed to create session on %s: %w", session.Fqdn, err)
+	}
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := shell.RequestPty("vt100", 80, 200, modes); err != nil {
+		client.Close()
+		return fmt.Errorf("request for pseudo-terminal failed for %s: %w", session.Fqdn, err)
+	}
+	stdin, err := shell.StdinPipe()
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("unable to setup stdin for session on %s: %w", session.Fqdn, err)
+	}
+	stdout, err := shell.StdoutPipe()
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("unable to setup stdout for session on %s: %w", session.Fqdn, err)
+	}
+	if err := shell.Shell(); err != nil {
+		client.Close()
+		return fmt.Errorf("failed to start shell on %s: %w", session.Fqdn, err)
+	}
+
+	session.client, session.session, session.stdin = client, shell, stdin
+	session.output = &session_output{notify: make(chan struct{}, 1)}
+	go session.output.read(stdout)
+
+	// The login banner ends with the first prompt, which every later output ends with.
+	output, err := session.wait(ctx, func(output string) bool { return session_prompt.MatchString(output) })
+	if err != nil {
+		session.close()
+		return fmt.Errorf("no prompt from %s: %w", session.Fqdn, err)
+	}
+	session.prompt = session_prompt.FindStringSubmatch(strings.ReplaceAll(output, "\r", ""))[1]
+
+	if _, err := session.exec(ctx, "terminal length 0"); err != nil {
+		session.close()
+		return err
+	}
+	return nil
+}
+
+// exec writes the command and waits for the prompt on a line of its own.
+func (session *Session) exec(parent context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, session_command_timeout)
+	defer cancel()
+
+	session.output.take()
+	if _, err := fmt.Fprintf(session.stdin, "%s\n", command); err != nil {
+		return "", fmt.Errorf("failed to write to stdin on %s: %w", session.Fqdn, err)
+	}
+	output, err := session.wait(ctx, func(output string) bool {
+		return strings.HasSuffix(strings.TrimRight(output, " "), "\n"+session.prompt)
+	})
+	if errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+		return "", fmt.Errorf("%s command timed out after %s", command, session_command_timeout)
+	}
+	return output, err
+}
+
+// wait returns the output read since the last take once done accepts it.
+func (session *Session) wait(ctx context.Context, done func(string) bool) (string, error) {
+	for {
+		output, err := session.output.peek()
+		if done(output) {
+			return session.output.take(), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("session on %s closed: %w", session.Fqdn, err)
+		}
+		select {
+		case <-session.output.notify:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// read copies the shell output into buf until the connection closes.
+func (output *session_output) read(stdout io.Reader) {
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := stdout.Read(chunk)
+		output.mu.Lock()
+		output.buf.Write(chunk[:n])
+		output.err = err
+		output.mu.Unlock()
+
+		select {
+		case output.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// peek returns the output not taken yet without carriage returns, and the read error.
+func (output *session_output) peek() (string, error) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	return strings.ReplaceAll(output.buf.String(), "\r", ""), output.err
+}
+
+// take returns the output not taken yet as it was read and empties the buffer.
+func (output *session_output) take() string {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	text := output.buf.String()
+	output.buf.Reset()
+	return text
+}
+
+func (session *Session) close() error {
+	if session.client == nil {
+		return nil
+	}
+	session.session.Close()
+	err := session.client.Close()
+	session.client, session.session, session.stdin = nil, nil, nil
+	return err
+}
+
+// dial_ssh opens an SSH connection with the credentials and algorithms of the cisco package.
+func dial_ssh(ctx context.Context, fqdn string) (*ssh.Client, error) {
+	address := net.JoinHostPort(fqdn, ssh_port)
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	// The deadline covers the handshake only, a Session outlives ctx.
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, address, ssh_client_config())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, channels, requests), nil
+}
+
+// ssh_client_config matches the cisco package, so old switches can still be reached.
+func ssh_client_config() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            os.Getenv("CISCO_USERNAME"),
+		Auth:            []ssh.AuthMethod{ssh.Password(os.Getenv("CISCO_PASSWORD"))},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         probe_timeout,
+		Config: ssh.Config{
+			Ciphers: []string{
+				"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+				"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-cbc",
+			},
+			KeyExchanges: []string{
+				"curve25519-sha256", "ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+				"diffie-hellman-group14-sha256", "diffie-hellman-group1-sha1", "diffie-hellman-group14-sha1",
+			},
+		},
+	}
+}
+
+type session_key struct{}
+
+// With_session returns a context whose collectors run their commands over session
+// when they collect from session.Fqdn.
+func With_session(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, session_key{}, session)
+}
+
+// Session_from_context returns the session set with With_session, or nil when there is none.
+func Session_from_context(ctx context.Context) *Session {
+	session, _ := ctx.Value(session_key{}).(*Session)
+	return session
+}
+
+// in_session reports whether the commands for switch_hostname run over the session of ctx.
+// Without one the collectors call the cisco package show functions, which log in and parse themselves;
+// over a session they parse the output with the copies of those parsers, which the package does not export.
+func in_session(ctx context.Context, switch_hostname string) bool {
+	session := Session_from_context(ctx)
+	return session != nil && session.Fqdn == switch_hostname
+}
+
+// run_command runs a command on the switch with the retry policy of the Store, over the
+// session of ctx when it is open on this switch, otherwise with a login of its own.
+func (s *Store) run_command(ctx context.Context, switch_hostname string, command string) (string, error) {
+	session := Session_from_context(ctx)
+	return retry_command(ctx, s.retry_policy(), switch_hostname, command, func() (string, error) {
+		if in_session(ctx, switch_hostname) {
+			return session.Run(ctx, command)
+		}
+		return cisco.RunCommand(switch_hostname, command)
+	})
+}
@@ -0,0 +1,135 @@
+package cisco_database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+)
+
+// Store holds the long-lived database handle shared by every collector,
+// Update_interfaces_* enricher and Device_* query.
+// Create one with New_store and reuse it for a whole run; it is safe for concurrent use.
+type Store struct {
+	DB *sql.DB
+	// Collectors holds the steps run by Process_switch for each switch.
+	Collectors *Registry
+	// Exporter, when set, writes the running-configs to a git repository, committed when each run finishes.
+	Exporter *GitExporter
+	// Redactor removes secrets from configs before they are stored or exported. Nil uses the built-in rules.
+	Redactor *Redactor
+	// Compliance, when set, is checked by the Check_compliance step against every collected config.
+	Compliance *ComplianceRules
+	// Retry is the policy for failed device commands. The zero value means Default_retry_policy.
+	Retry RetryPolicy
+	// SingleSession makes Run_collectors open one Session per switch for all its commands.
+	SingleSession bool
+	// GatewayRoles are the switch roles Show_ip_arp runs on. Empty means Default_gateway_roles.
+	GatewayRoles []string
+	// ReverseDNS resolves the names stored by Update_fqdn_table. Nil uses the system resolver.
+	ReverseDNS *ReverseDNS
+	// ISE, when set, is the client Import_ise reads the endpoints from.
+	ISE *ISEClient
+}
+
+var (
+	defaultStoreMu sync.Mutex
+	defaultStore   *Store
+)
+
+// New_store opens a connection pool with the given Config.
+func New_store(cfg Config) (*Store, error) {
+	return New_store_context(context.Background(), cfg)
+}
+
+// New_store_context is New_store with a context bounding the initial connection.
+func New_store_context(ctx context.Context, cfg Config) (*Store, error) {
+	db, err := DB_connect_config_context(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := New_store_from_db(db)
+	s.Retry = cfg.Retry
+	s.SingleSession = cfg.SingleSession
+	s.GatewayRoles = cfg.GatewayRoles
+	s.ReverseDNS = New_reverse_dns(cfg.ReverseDNS)
+	if s.Redactor, err = New_redactor(cfg.Redaction); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if cfg.ISE.URL != "" {
+		if s.ISE, err = New_ise_client(cfg.ISE); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if cfg.ComplianceRules != "" {
+		if s.Compliance, err = Load_compliance_rules(cfg.ComplianceRules); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if cfg.GitBackup.Path != "" {
+		s.Exporter, err = New_git_exporter(cfg.GitBackup)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// New_store_from_db wraps an already opened database handle.
+func New_store_from_db(db *sql.DB) *Store {
+	s := &Store{DB: db}
+	s.Collectors = Default_registry(s)
+	return s
+}
+
+// Close closes the connection pool.
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
+
+// Default_store returns the Store used by the package-level functions.
+// It is opened on first use with Get_config and kept for the life of the process.
+func Default_store() (*Store, error) {
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+
+	if defaultStore != nil {
+		return defaultStore, nil
+	}
+
+	cfg, err := Get_config()
+	if err != nil {
+		return nil, err
+	}
+	s, err := New_store(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defaultStore = s
+	return defaultStore, nil
+}
+
+// Set_default_store makes the package-level functions use the given Store.
+func Set_default_store(s *Store) {
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+	defaultStore = s
+}
+
+// reset_default_store closes the default Store so it is reopened on next use.
+func reset_default_store() {
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+
+	if defaultStore != nil {
+		if err := defaultStore.Close(); err != nil {
+			log.Printf("Error closing default store: %v", err)
+		}
+		defaultStore = nil
+	}
+}